@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// fileCleanup tracks temporary and partially written files that must not
+// outlive an aborted run
+type fileCleanup struct {
+	mu    sync.Mutex
+	paths map[string]bool
+
+	// cancel, when set, is called on the first interrupt instead of exiting
+	cancel context.CancelCauseFunc
+}
+
+// errInterrupted is the cause of the export context when sql2csv receives
+// SIGINT or SIGTERM
+var errInterrupted = errors.New("interrupted")
+
+// cleanupFiles is removed by fatalf and on interrupt
+var cleanupFiles = &fileCleanup{paths: make(map[string]bool)}
+
+// add registers a file for removal
+func (c *fileCleanup) add(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paths[path] = true
+}
+
+// forget unregisters a file that is complete or cleaned up elsewhere
+func (c *fileCleanup) forget(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.paths, path)
+}
+
+// run removes all registered files
+func (c *fileCleanup) run() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for path := range c.paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			warnf("failed to remove %s: %v\n", path, err)
+		}
+		delete(c.paths, path)
+	}
+}
+
+// cancelOnSignal makes the first interrupt cancel the exports with
+// errInterrupted, so they stop cleanly, instead of exiting right away
+func (c *fileCleanup) cancelOnSignal(cancel context.CancelCauseFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cancel = cancel
+}
+
+// handleSignals removes the registered files and exits when the process is
+// interrupted, unless cancelOnSignal was called; a second interrupt always
+// exits. The returned function stops the handler.
+func (c *fileCleanup) handleSignals() func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				c.mu.Lock()
+				cancel := c.cancel
+				c.cancel = nil
+				c.mu.Unlock()
+				if cancel != nil {
+					infof("Received %v, stopping exports (interrupt again to exit now)\n", sig)
+					cancel(errInterrupted)
+					continue
+				}
+
+				infof("Received %v, removing temporary and partial files\n", sig)
+				c.run()
+				os.Exit(130)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// fatalf removes the registered files before logging and exiting, since
+// log.Fatalf skips deferred cleanup
+func fatalf(format string, args ...interface{}) {
+	cleanupFiles.run()
+	log.Fatalf(format, args...)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"sql2csv/pkg/cli"
+	"sql2csv/pkg/config"
+)
+
+// configOverrides lists, for flags that replace other settings, the file
+// settings they override besides their own
+var configOverrides = map[string][]string{
+	"table-pattern":      {"tables"},
+	"columns-from-query": {"columns"},
+	"host":               {"socket"},
+	"socket":             {"host"},
+	"conn":               {"host", "socket", "port", "user", "dbname", "sslmode", "schema"},
+	"dump":               {"host", "socket", "port", "user", "dbname", "conn", "sslmode", "schema"},
+}
+
+// applyConfigFile fills in the flags not given on the command line from a
+// config file. The file's password is used unless PasswordEnv is set, since
+// the prompts and ConfigFromFlags read it from there.
+func applyConfigFile(path string) {
+	file, err := config.Load(path)
+	if err != nil {
+		fatalf("Error loading -config: %v", err)
+	}
+
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+		for _, name := range configOverrides[f.Name] {
+			given[name] = true
+		}
+	})
+	for name, value := range file.Flags() {
+		if given[name] {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			fatalf("Error in -config: invalid %s %q: %v", name, value, err)
+		}
+	}
+
+	if _, ok := os.LookupEnv(cli.PasswordEnv); !ok && file.Database.Password != "" {
+		os.Setenv(cli.PasswordEnv, file.Database.Password)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sql2csv/pkg/cli"
+	"sql2csv/pkg/database"
+	"sql2csv/pkg/exporter"
+	"strings"
+)
+
+// runDiff exports the rows that differ between the two tables named by
+// -diff as tableA:tableB
+func runDiff(db *sql.DB, dbType database.DBType) int {
+	tableA, tableB, ok := strings.Cut(*diffTables, ":")
+	if !ok || tableA == "" || tableB == "" {
+		fatalf("Error: -diff must be two table names as tableA:tableB, got %q", *diffTables)
+	}
+	if *diffKey == "" {
+		fatalf("Error: -diff requires -diff-key")
+	}
+
+	if _, err := cli.CheckTables(db, dbType, []string{tableA, tableB}); err != nil {
+		fatalf("Error selecting tables: %v", err)
+	}
+
+	// Both tables must have the same columns, in any order
+	columns, err := database.GetColumns(db, dbType, tableA)
+	if err != nil {
+		fatalf("Error getting columns for table %s: %v", tableA, err)
+	}
+	columnsB, err := database.GetColumns(db, dbType, tableB)
+	if err != nil {
+		fatalf("Error getting columns for table %s: %v", tableB, err)
+	}
+	onlyB, onlyA := database.DiffColumns(columns, columnsB)
+	if len(onlyA) > 0 || len(onlyB) > 0 {
+		fatalf("Error: tables %s and %s have different columns (only in %s: %v, only in %s: %v)",
+			tableA, tableB, tableA, onlyA, tableB, onlyB)
+	}
+
+	query, err := database.DiffQuery(dbType, tableA, tableB, columns, *diffKey)
+	if err != nil {
+		fatalf("Error building diff query: %v", err)
+	}
+
+	outputDir := *outputFlag
+	if outputDir == "" {
+		outputDir, err = cli.SelectOutputDir()
+		if err != nil {
+			fatalf("Error selecting output directory: %v", err)
+		}
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		fatalf("Error creating output directory: %v", err)
+	}
+
+	outputPath := filepath.Join(outputDir, fmt.Sprintf("%s_vs_%s.csv", tableA, tableB))
+	exp := exporter.NewQueryExporter(db, query, outputPath)
+	exp.OnTempFile = cleanupFiles.add
+	if err := exp.Export(); err != nil {
+		fatalf("Error exporting diff of %s and %s: %v", tableA, tableB, err)
+	}
+
+	infof("Exported %d differing rows of %s and %s to %s\n",
+		exp.Stats().Rows, tableA, tableB, outputPath)
+	return 0
+}
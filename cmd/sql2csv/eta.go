@@ -0,0 +1,54 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// etaSmoothing is the time constant of the throughput average behind the
+// ETA: rates measured longer ago than this weigh little
+const etaSmoothing = 10 * time.Second
+
+// etaEstimator estimates the time left in a table's export from an
+// exponentially weighted average of its recent throughput, so one slow or
+// fast batch doesn't make the estimate jump
+type etaEstimator struct {
+	total    int64
+	lastRows int64
+	lastTime time.Time
+	rate     float64 // rows per second
+}
+
+// newETAEstimator starts estimating an export of total rows
+func newETAEstimator(total int64) *etaEstimator {
+	return &etaEstimator{total: total}
+}
+
+// update records the rows written so far and returns the estimated time
+// left; ok is false until a throughput has been measured
+func (e *etaEstimator) update(rows int64, now time.Time) (left time.Duration, ok bool) {
+	// Measure from the first batch, so time spent waiting to start or for
+	// the query to return its first rows doesn't count
+	if e.lastTime.IsZero() {
+		e.lastRows, e.lastTime = rows, now
+		return 0, false
+	}
+
+	elapsed := now.Sub(e.lastTime).Seconds()
+	if elapsed > 0 && rows > e.lastRows {
+		rate := float64(rows-e.lastRows) / elapsed
+		if e.rate == 0 {
+			e.rate = rate
+		} else {
+			weight := 1 - math.Exp(-elapsed/etaSmoothing.Seconds())
+			e.rate += weight * (rate - e.rate)
+		}
+		e.lastRows, e.lastTime = rows, now
+	}
+	if e.rate == 0 {
+		return 0, false
+	}
+
+	remaining := max(e.total-rows, 0)
+	return time.Duration(float64(remaining) / e.rate * float64(time.Second)), true
+}
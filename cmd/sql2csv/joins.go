@@ -0,0 +1,83 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"sql2csv/pkg/database"
+	"sql2csv/pkg/exporter"
+	"strings"
+)
+
+// joinFlag collects the -join flags by exported table. Each flag is
+// TABLE:JOINED:TABLE.COLUMN=JOINED.COLUMN:JOINED.COLUMN[,...]; the table
+// prefixes are optional.
+type joinFlag map[string][]exporter.Join
+
+func (f joinFlag) String() string {
+	return fmt.Sprintf("%d joins", len(f))
+}
+
+func (f joinFlag) Set(value string) error {
+	table, join, err := parseJoin(value)
+	if err != nil {
+		return err
+	}
+	f[table] = append(f[table], join)
+	return nil
+}
+
+// parseJoin parses one -join flag into the exported table and its join
+func parseJoin(value string) (string, exporter.Join, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 4 || parts[0] == "" || parts[1] == "" {
+		return "", exporter.Join{}, fmt.Errorf("expected table:joined:table.column=joined.column:joined.column, got %q", value)
+	}
+	table, joined := parts[0], parts[1]
+
+	left, right, ok := strings.Cut(parts[2], "=")
+	if !ok {
+		return "", exporter.Join{}, fmt.Errorf("expected a join condition like table.column=joined.column, got %q", parts[2])
+	}
+	left, right = strings.TrimSpace(left), strings.TrimSpace(right)
+	// Accept the condition either way round
+	if strings.HasPrefix(left, joined+".") && strings.HasPrefix(right, table+".") {
+		left, right = right, left
+	}
+
+	join := exporter.Join{
+		Table:      joined,
+		Column:     strings.TrimPrefix(left, table+"."),
+		JoinColumn: strings.TrimPrefix(right, joined+"."),
+	}
+	for _, column := range splitList(parts[3]) {
+		join.Columns = append(join.Columns, strings.TrimPrefix(column, joined+"."))
+	}
+	if join.Column == "" || join.JoinColumn == "" || len(join.Columns) == 0 {
+		return "", exporter.Join{}, fmt.Errorf("join %q needs both join columns and at least one column to export", value)
+	}
+	return table, join, nil
+}
+
+// checkJoins makes sure every joined table is joined to a selected table and
+// has the columns the join uses. The exporter checks the selected table's
+// side.
+func checkJoins(db *sql.DB, dbType database.DBType, joins joinFlag, selected []string) error {
+	for table, tableJoins := range joins {
+		if !slices.Contains(selected, table) {
+			return fmt.Errorf("table %s has joins but isn't selected for export", table)
+		}
+		for _, join := range tableJoins {
+			columns, err := database.GetColumns(db, dbType, join.Table)
+			if err != nil {
+				return fmt.Errorf("error getting columns for joined table %s: %w", join.Table, err)
+			}
+			for _, column := range append([]string{join.JoinColumn}, join.Columns...) {
+				if !slices.Contains(columns, column) {
+					return fmt.Errorf("column %s not found in joined table %s", column, join.Table)
+				}
+			}
+		}
+	}
+	return nil
+}
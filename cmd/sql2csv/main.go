@@ -1,99 +1,979 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"math"
 	"os"
 	"path/filepath"
+	"runtime/debug"
+	"sort"
 	"sql2csv/pkg/cli"
 	"sql2csv/pkg/database"
+	"sql2csv/pkg/duckdb"
 	"sql2csv/pkg/exporter"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
 )
 
+// Non-interactive mode: connection, table and output settings that replace
+// the corresponding prompts
+var (
+	dbType       = flag.String("type", "", "database type: mysql, mariadb, postgres, sqlite3 or awsathena")
+	dbHost       = flag.String("host", "", "database host (default localhost)")
+	dbSocket     = flag.String("socket", "", "Unix socket of a local mysql, mariadb or postgres server, instead of -host, e.g. /var/run/postgresql")
+	dbPort       = flag.String("port", "", "database port (default 3306 for mysql and mariadb and 5432 for postgres)")
+	dbUser       = flag.String("user", "", "database user; the password is read from $"+cli.PasswordEnv)
+	dbName       = flag.String("dbname", "", "database name, or the database file path for sqlite3")
+	connString   = flag.String("conn", "", "connection string, instead of -host/-port/-user/-dbname (default $"+cli.ConnEnv+")")
+	sslMode      = flag.String("sslmode", "", "postgres sslmode: disable, require, verify-ca or verify-full (default disable)")
+	schemaName   = flag.String("schema", "", "postgres schema to list and export tables from (default public)")
+	dumpFile     = flag.String("dump", "", "import this SQL dump, or - for stdin, written from the -type database (mysql, postgres or mariadb)")
+	tablesFlag   = flag.String("tables", "", "comma-separated tables to export instead of prompting")
+	tablePattern = flag.String("table-pattern", "", "export every table matching this glob, e.g. user_*, instead of prompting")
+	outputFlag   = flag.String("output", "", "output directory instead of prompting")
+	fileTemplate = flag.String("filename-template", "", "name output files with {table}, {db}, {date} and {time}, e.g. {db}_{table}_{date}; the extension is appended")
+	manifestFlag = flag.Bool("manifest", false, "write manifest.json to the output directory, listing each table's files, rows, bytes and error")
+	diffTables   = flag.String("diff", "", "export the rows that differ between two tables, given as tableA:tableB")
+	diffKey      = flag.String("diff-key", "", "key column the -diff output is ordered by")
+	configFile   = flag.String("config", "", "YAML or JSON file with connection, table and export settings; flags override it")
+)
+
+var (
+	queryTemplate = flag.String("query-template", "",
+		"custom export query with {columns}, {table}, {where}, {order} and {limit} placeholders")
+	columnsFlag    = flag.String("columns", "", "comma-separated columns to export, in table order (default all)")
+	columnsQuery   = flag.String("columns-from-query", "", "metadata query returning the columns to export; {table} is replaced with the table name")
+	excludeColumns = flag.String("exclude-columns", "", "comma-separated columns to leave out of the export")
+	where          = flag.String("where", "", "SQL predicate appended as WHERE <clause> to every table's export query")
+	orderBy        = flag.String("order-by", "", "SQL ORDER BY list for every table's export query, e.g. id (default the primary key)")
+	unordered      = flag.Bool("unordered", false, "don't order exports by the primary key when -order-by isn't given")
+	limit          = flag.Int("limit", 0, "export at most this many rows per table (0 exports all rows)")
+	tableSample    = flag.Float64("tablesample", 0, "export about this percentage of each table's rows, e.g. 1 for 1%")
+	delimiter      = flag.String("delimiter", ",",
+		`CSV field delimiter: a single character, or "\t"/"tab" for tab-separated output`)
+	format     = flag.String("format", "csv", "output format: csv, json, jsonl, sql or xlsx")
+	prettyJSON = flag.Bool("pretty-json", false, "indent the objects of the json format instead of writing one per line")
+	sqlDialect = flag.String("sql-dialect", "",
+		"database type whose quoting rules the sql format uses (defaults to the source type)")
+	readOnlyCheck   = flag.Bool("readonly-check", false, "abort unless the database user is unable to modify data")
+	includeRegex    = flag.String("include-regex", "", "only offer tables whose names match this regular expression")
+	estimateCounts  = flag.Bool("estimate-counts", false, "list tables with the row counts kept in the database's statistics instead of counting them")
+	sortTables      = flag.String("sort-tables", "", "order of the table selection prompt: name, name-desc, rows (largest first) or rows-asc")
+	excludeRegex    = flag.String("exclude-regex", "", "never offer tables whose names match this regular expression")
+	toDuckDB        = flag.String("to-duckdb", "", "load the selected tables into this DuckDB database file instead of writing files")
+	commonColumns   = flag.Bool("common-columns", false, "export only the columns every selected table has, in the same order")
+	verifySchema    = flag.Bool("verify-schema", false, "skip tables whose columns changed between selection and export")
+	requireNonEmpty = flag.Bool("require-nonempty", false, "fail with a non-zero exit code if any exported table has no rows")
+	skipEmpty       = flag.Bool("skip-empty", false, "don't export tables without rows, instead of writing a file with only the header")
+	skipBadRows     = flag.Bool("skip-bad-rows", false, "skip rows that fail to scan instead of aborting the table")
+	boolFormat      = flag.String("bool-format", "", "write booleans in CSV output as true-false, 1-0, yes-no or TRUE/FALSE-style custom spellings")
+	binaryEncoding  = flag.String("binary-encoding", "", "write binary columns in CSV output as raw bytes (the default), hex or base64")
+	validateSchema  = flag.String("validate-schema", "", "fail exports whose columns don't match this JSON schema file")
+	timeLayout      = flag.String("time-layout", "", "Go time layout of date and time values in CSV output, e.g. '2006-01-02 15:04:05' (default RFC 3339)")
+	repeatHeader    = flag.Int("repeat-header", 0, "write the CSV header again every N data rows, for reading files in a pager")
+	maxRowsPerFile  = flag.Int("max-rows-per-file", 0, "split each table into files of at most N rows, named <table>_0001.csv and so on")
+	maxBytesPerFile = flag.String("max-bytes-per-file", "", "split each table into files of at most this size, e.g. 100MB, measured before -gzip compression")
+	nullString      = flag.String("null-string", "", `text written for NULL values in CSV output, e.g. \N or NULL`)
+	compress        = flag.Bool("gzip", false, "gzip the export files, adding a .gz extension")
+	incremental     = flag.String("incremental-column", "", "append only rows beyond the last value of this column in the existing CSV file")
+	mysqlGeomAsWKT  = flag.Bool("mysql-geom-as-wkt", false, "export MySQL spatial columns as WKT text via ST_AsText instead of WKB binary")
+	castText        = flag.Bool("cast-text", false, "select every column through CAST(... AS <text type>) so values arrive as plain strings")
+	retryFailed     = flag.Bool("retry-failed-statements", false, "retry dump statements that failed during import once the whole dump is read")
+	exactNumeric    = flag.Bool("exact-numeric", false, "import DECIMAL/NUMERIC dump columns as text instead of floating point")
+	copyEmptyNull   = flag.Bool("copy-empty-as-null", false, `import empty fields of PostgreSQL COPY data as NULL, not only \N`)
+	inMemoryImport  = flag.Bool("in-memory-import", false, "import SQL dumps into an in-memory database instead of a temporary file")
+	keepConstraints = flag.Bool("keep-constraints", false, "add the keys and checks of ALTER TABLE ... ADD CONSTRAINT dump statements to the imported tables")
+	dumpLineSize    = flag.String("dump-max-line", "", "longest line of a SQL dump that can be imported, e.g. 64MB (default 10MB)")
+	commentHeader   = flag.Bool("comment-header", false, "write a second CSV header row with each column's comment")
+	externalText    = flag.Int("external-text-threshold", 0, "write text values over this many bytes to docs/<table>_<key>_<column>.txt and export the path instead")
+	rowHash         = flag.Bool("row-hash", false, "append a __row_hash column with the SHA-256 of each row's values")
+	batchSizeFlag   = flag.Int("batch-size", exporter.DefaultBatchSize, "rows buffered per write; lower it for very wide tables")
+	adaptiveMemory  = flag.String("adaptive-memory", "", "shrink batches and pause exports to keep the heap under this size, e.g. 512MB")
+	readTimeout     = flag.Duration("read-timeout", 0, "fail a table's export when no row arrives for this long (e.g. 2m)")
+	quiet           = flag.Bool("q", false, "quiet: only report warnings and errors, not the progress and success of each table")
+	verbose         = flag.Bool("v", false, "verbose: also print each table's export query, row count and time")
+	toStdout        = flag.Bool("stdout", false, "write the export of a single selected table to stdout instead of a file")
+	concurrency     = flag.Int("concurrency", 4, "export at most this many tables at once")
+	connectTimeout  = flag.Duration("connect-timeout", database.DefaultConnectTimeout, "give up connecting when the database doesn't answer for this long (negative means wait forever)")
+	maxOpenConns    = flag.Int("max-open-conns", database.DefaultMaxOpenConns, "most database connections open at once; tables beyond it wait (negative means no limit)")
+	maxIdleConns    = flag.Int("max-idle-conns", 0, "database connections kept open while unused (default -max-open-conns, negative means none)")
+	connMaxLifetime = flag.Duration("conn-max-lifetime", 0, "close database connections once they have been open this long (e.g. 30m)")
+	maxDuration     = flag.Duration("max-duration", 0, "stop exporting after this long, keeping the rows written so far (e.g. 10m)")
+)
+
+// nullFlags holds the repeatable -null flag
+var nullFlags nullFlag
+
+// joins holds the repeatable -join flag
+var joins = make(joinFlag)
+
+func init() {
+	flag.Var(&nullFlags, "null", "NULL replacement as table.column=token, column=token or default=token (repeatable)")
+	flag.Var(joins, "join", "add columns of another table as table:joined:table.column=joined.column:joined.column[,...] (repeatable)")
+}
+
+// progressInterval is how many rows a table exports between progress lines
+const progressInterval = 50000
+
 func main() {
-	// Get database configuration from user
-	config, err := cli.DatabaseConfig()
+	os.Exit(run())
+}
+
+// run performs the export and returns the process exit code, so deferred
+// cleanup runs before the program exits
+func run() int {
+	flag.Parse()
+	if *configFile != "" {
+		applyConfigFile(*configFile)
+	}
+
+	if *quiet && *verbose {
+		fatalf("Error: -q and -v cannot be combined")
+	}
+
+	if *toStdout {
+		status = os.Stderr
+		if *toDuckDB != "" || *incremental != "" {
+			fatalf("Error: -stdout cannot be combined with -to-duckdb or -incremental-column")
+		}
+	}
+
+	stopSignals := cleanupFiles.handleSignals()
+	defer stopSignals()
+
+	// Cancelled with errInterrupted when the exports are interrupted
+	ctx, interrupt := context.WithCancelCause(context.Background())
+	defer interrupt(nil)
+
+	filter, err := database.NewTableFilter(*tablePattern, *includeRegex, *excludeRegex)
+	if err != nil {
+		fatalf("Error parsing table filters: %v", err)
+	}
+	if *tableSample < 0 || *tableSample > 100 {
+		fatalf("Error: -tablesample must be a percentage between 0 and 100, got %v", *tableSample)
+	}
+	if *tablePattern != "" && *tablesFlag != "" {
+		fatalf("Error: -table-pattern and -tables cannot be combined")
+	}
+
+	if *commentHeader && (*format != string(exporter.CSV) || *incremental != "") {
+		fatalf("Error: -comment-header requires CSV output and cannot be combined with -incremental-column")
+	}
+
+	if *externalText > 0 && (*toStdout || *toDuckDB != "") {
+		fatalf("Error: -external-text-threshold requires file output and cannot be combined with -stdout or -to-duckdb")
+	}
+
+	if *columnsFlag != "" && *columnsQuery != "" {
+		fatalf("Error: -columns and -columns-from-query cannot be combined")
+	}
+
+	nulls := parseNullTokens(nullFlags)
+
+	bools, err := exporter.ParseBoolFormat(*boolFormat)
+	if err != nil {
+		fatalf("Error parsing -bool-format: %v", err)
+	}
+	if bools != "" && *format != string(exporter.CSV) {
+		fatalf("Error: -bool-format requires CSV output")
+	}
+	if *timeLayout != "" && *format != string(exporter.CSV) {
+		fatalf("Error: -time-layout requires CSV output")
+	}
+	binaries, err := exporter.ParseBinaryEncoding(*binaryEncoding)
+	if err != nil {
+		fatalf("Error parsing -binary-encoding: %v", err)
+	}
+	if binaries != "" && binaries != exporter.BinaryRaw && *format != string(exporter.CSV) {
+		fatalf("Error: -binary-encoding requires CSV output")
+	}
+	dialect, err := exporter.ParseSQLDialect(*sqlDialect)
+	if err != nil {
+		fatalf("Error parsing -sql-dialect: %v", err)
+	}
+	if *repeatHeader < 0 {
+		fatalf("Error: -repeat-header must not be negative")
+	}
+	if *repeatHeader > 0 && *format != string(exporter.CSV) {
+		fatalf("Error: -repeat-header requires CSV output")
+	}
+	if *orderBy != "" && *incremental != "" {
+		fatalf("Error: -order-by cannot be combined with -incremental-column, whose exports are ordered by that column")
+	}
+	if *concurrency < 1 {
+		fatalf("Error: -concurrency must be at least 1")
+	}
+	if *maxRowsPerFile < 0 {
+		fatalf("Error: -max-rows-per-file must not be negative")
+	}
+	if *maxRowsPerFile > 0 && (*toStdout || *toDuckDB != "" || *incremental != "") {
+		fatalf("Error: -max-rows-per-file requires file output and cannot be combined with -stdout, -to-duckdb or -incremental-column")
+	}
+	var maxFileSize uint64
+	if *maxBytesPerFile != "" {
+		maxFileSize, err = parseByteSize(*maxBytesPerFile)
+		if err != nil {
+			fatalf("Error parsing -max-bytes-per-file: %v", err)
+		}
+		if maxFileSize == 0 || maxFileSize > math.MaxInt64 {
+			fatalf("Error: -max-bytes-per-file must be between 1 byte and 8EB")
+		}
+		if *toStdout || *toDuckDB != "" || *incremental != "" {
+			fatalf("Error: -max-bytes-per-file requires file output and cannot be combined with -stdout, -to-duckdb or -incremental-column")
+		}
+		if *format == string(exporter.XLSX) || *repeatHeader > 0 {
+			fatalf("Error: -max-bytes-per-file cannot be combined with -format xlsx or -repeat-header")
+		}
+	}
+	if *compress && *format == string(exporter.XLSX) {
+		fatalf("Error: -gzip cannot be combined with -format xlsx")
+	}
+	if err := exporter.CheckFileNameTemplate(*fileTemplate); err != nil {
+		fatalf("Error in -filename-template: %v", err)
+	}
+	if *fileTemplate != "" && (*toStdout || *toDuckDB != "") {
+		fatalf("Error: -filename-template requires file output and cannot be combined with -stdout or -to-duckdb")
+	}
+	if *prettyJSON && *format != string(exporter.JSON) {
+		fatalf("Error: -pretty-json requires -format json")
+	}
+	if *manifestFlag && (*toStdout || *toDuckDB != "") {
+		fatalf("Error: -manifest requires file output and cannot be combined with -stdout or -to-duckdb")
+	}
+
+	var memoryLimit uint64
+	if *adaptiveMemory != "" {
+		memoryLimit, err = parseByteSize(*adaptiveMemory)
+		if err != nil {
+			fatalf("Error parsing -adaptive-memory: %v", err)
+		}
+		// Have the garbage collector work harder near the ceiling too
+		debug.SetMemoryLimit(int64(memoryLimit))
+	}
+
+	var schema *exporter.Schema
+	if *validateSchema != "" {
+		schema, err = exporter.LoadSchema(*validateSchema)
+		if err != nil {
+			fatalf("Error loading -validate-schema: %v", err)
+		}
+	}
+
+	var maxLineSize int
+	if *dumpLineSize != "" {
+		size, err := parseByteSize(*dumpLineSize)
+		if err != nil {
+			fatalf("Error parsing -dump-max-line: %v", err)
+		}
+		if size > math.MaxInt32 {
+			fatalf("Error: -dump-max-line must be under 2GB")
+		}
+		maxLineSize = int(size)
+	}
+
+	tableOrder, err := database.ParseTableOrder(*sortTables)
+	if err != nil {
+		fatalf("Error parsing -sort-tables: %v", err)
+	}
+
+	csvDelimiter, err := parseDelimiter(*delimiter)
+	if err != nil {
+		fatalf("Error parsing delimiter: %v", err)
+	}
+
+	// Get database configuration from the flags, or else from the user
+	var config database.Config
+	var importedDB string // temporary database of an imported dump
+	connFlags := cli.ConnectionFlags{
+		Type:          *dbType,
+		Host:          *dbHost,
+		Socket:        *dbSocket,
+		Port:          *dbPort,
+		User:          *dbUser,
+		DBName:        *dbName,
+		ConnectionURL: *connString,
+		SSLMode:       *sslMode,
+		Schema:        *schemaName,
+	}
+	importOpts := cli.ImportOptions{
+		RetryFailed:     *retryFailed,
+		ExactNumeric:    *exactNumeric,
+		EmptyAsNull:     *copyEmptyNull,
+		MaxLineSize:     maxLineSize,
+		KeepConstraints: *keepConstraints,
+		InMemory:        *inMemoryImport,
+		Debug:           *verbose,
+		OnTempFile: func(path string) {
+			cleanupFiles.add(path)
+			importedDB = path
+		},
+	}
+	switch {
+	case *dumpFile != "":
+		if connFlags != (cli.ConnectionFlags{Type: *dbType}) {
+			fatalf("Error: -dump cannot be combined with -host, -socket, -port, -user, -dbname, -conn, -sslmode or -schema")
+		}
+		if *dbType == "" {
+			fatalf("Error: -dump requires -type, the database the dump was written from")
+		}
+		config, err = cli.ImportDump(*dumpFile, database.DBType(*dbType), importOpts)
+	case connFlags.IsSet():
+		config, err = cli.ConfigFromFlags(connFlags)
+	default:
+		config, err = cli.DatabaseConfig(importOpts)
+	}
 	if err != nil {
-		log.Fatalf("Error getting database configuration: %v", err)
+		fatalf("Error getting database configuration: %v", err)
 	}
+	config.ConnectTimeout = *connectTimeout
+	config.MaxOpenConns = *maxOpenConns
+	config.MaxIdleConns = *maxIdleConns
+	config.ConnMaxLifetime = *connMaxLifetime
 
 	// Clean up temporary SQLite database if using SQL dump
-	if config.Type == database.SQLite && strings.Contains(config.FilePath, "sql_import_") {
-		defer os.Remove(config.FilePath)
+	if importedDB != "" {
+		defer func() {
+			cleanupFiles.forget(importedDB)
+			os.Remove(importedDB)
+		}()
 	}
 
 	// Connect to the database
-	db, err := database.Connect(config)
+	db, err := database.ConnectContext(ctx, config)
 	if err != nil {
-		log.Fatalf("Error connecting to database: %v", err)
+		fatalf("Error connecting to database: %v", err)
 	}
 	defer db.Close()
 
-	// Let user select tables to export
-	selectedTables, err := cli.SelectTables(db, config.Type)
-	if err != nil {
-		log.Fatalf("Error selecting tables: %v", err)
+	// Make sure the export can't modify data, if requested
+	if *readOnlyCheck {
+		readOnly, reason, err := database.CheckReadOnly(db, config.Type)
+		if err != nil {
+			fatalf("Error running read-only check: %v", err)
+		}
+		if !readOnly {
+			fatalf("Read-only check failed: %s", reason)
+		}
+		infof("Read-only check passed: %s\n", reason)
 	}
 
-	// Get output directory
-	outputDir, err := cli.SelectOutputDir()
+	// Compare two tables instead of exporting, if requested
+	if *diffTables != "" {
+		return runDiff(db, config.Type)
+	}
+
+	// Let user select tables to export. Row counts are only known for
+	// tables that were listed with them.
+	var selectedTables []string
+	rowCounts := make(map[string]int64)
+	if *tablesFlag != "" {
+		selectedTables, err = cli.CheckTables(db, config.Type, splitList(*tablesFlag))
+	} else {
+		var infos []database.TableInfo
+		if *tablePattern != "" {
+			infos, err = cli.MatchTables(db, config.Type, filter, tableOrder, *estimateCounts)
+		} else {
+			infos, err = cli.SelectTables(db, config.Type, filter, tableOrder, *estimateCounts)
+		}
+		for _, info := range infos {
+			selectedTables = append(selectedTables, info.Name)
+			if !info.Estimated {
+				rowCounts[info.Name] = info.RowCount
+			}
+		}
+	}
 	if err != nil {
-		log.Fatalf("Error selecting output directory: %v", err)
+		fatalf("Error selecting tables: %v", err)
+	}
+
+	// Leave out the empty tables, if requested, counting the rows of the
+	// tables selected without an exact count
+	if *skipEmpty {
+		var nonEmpty []string
+		for _, table := range selectedTables {
+			count, ok := rowCounts[table]
+			if !ok {
+				if count, err = database.CountRows(db, config.Type, table); err != nil {
+					fatalf("Error in -skip-empty: %v", err)
+				}
+			}
+			if count == 0 {
+				infof("Skipping empty table %s\n", table)
+				continue
+			}
+			nonEmpty = append(nonEmpty, table)
+		}
+		selectedTables = nonEmpty
+	}
+
+	// Filters make the row counts an upper bound, too rough for an ETA
+	if *where != "" || *queryTemplate != "" || *tableSample > 0 || *incremental != "" {
+		rowCounts = nil
+	}
+
+	// Postgres can't sample views with TABLESAMPLE, so find them to sample
+	// them row by row
+	isView := make(map[string]bool)
+	if *tableSample > 0 && config.Type == database.Postgres {
+		views, err := database.GetViews(db, config.Type)
+		if err != nil {
+			fatalf("Error listing views for -tablesample: %v", err)
+		}
+		for _, view := range views {
+			isView[view] = true
+		}
+	}
+	if err := checkJoins(db, config.Type, joins, selectedTables); err != nil {
+		fatalf("Error checking joins: %v", err)
+	}
+	if schema != nil {
+		for _, table := range selectedTables {
+			if _, ok := schema.Tables[table]; !ok {
+				fatalf("Error: table %s is not in schema file %s", table, *validateSchema)
+			}
+		}
+	}
+
+	// Capture the columns at selection time so they can be intersected, or
+	// checked again right before each export
+	selectedColumns := make(map[string][]string)
+	if *verifySchema || *commonColumns {
+		for _, table := range selectedTables {
+			columns, err := database.GetColumns(db, config.Type, table)
+			if err != nil {
+				fatalf("Error getting columns for table %s: %v", table, err)
+			}
+			selectedColumns[table] = columns
+		}
+	}
+
+	// Export only the columns every selected table has, if requested
+	var sharedColumns []string
+	if *commonColumns {
+		columnLists := make([][]string, len(selectedTables))
+		for i, table := range selectedTables {
+			columnLists[i] = selectedColumns[table]
+		}
+		sharedColumns = database.CommonColumns(columnLists)
+		if len(sharedColumns) == 0 {
+			fatalf("Error: the selected tables have no columns in common")
+		}
+		for _, table := range selectedTables {
+			if dropped, _ := database.DiffColumns(sharedColumns, selectedColumns[table]); len(dropped) > 0 {
+				infof("Leaving out columns of table %s that other tables lack: %s\n",
+					table, strings.Join(dropped, ", "))
+			}
+		}
+	}
+
+	// Streams can't be told apart on stdout, so only one table is allowed
+	if *toStdout && len(selectedTables) != 1 {
+		fatalf("Error: -stdout requires exactly one selected table, got %d", len(selectedTables))
+	}
+
+	// Load into DuckDB instead of writing files, if requested
+	var outputDir string
+	var loader *duckdb.Loader
+	if *toDuckDB != "" {
+		loader, err = duckdb.Open(*toDuckDB)
+		if err != nil {
+			fatalf("Error opening DuckDB database: %v", err)
+		}
+		defer loader.Close()
+	} else if !*toStdout {
+		// Get output directory
+		outputDir = *outputFlag
+		if outputDir == "" {
+			outputDir, err = cli.SelectOutputDir()
+			if err != nil {
+				fatalf("Error selecting output directory: %v", err)
+			}
+		}
+
+		// Create output directory if it doesn't exist
+		if err := os.MkdirAll(outputDir, 0755); err != nil {
+			fatalf("Error creating output directory: %v", err)
+		}
+	}
+
+	// Name the files of every table with the same {db}, {date} and {time}
+	var dbName string
+	exportTime := time.Now()
+	if strings.Contains(*fileTemplate, "{db}") {
+		dbName, err = databaseName(db, config)
+		if err != nil {
+			fatalf("Error in -filename-template: %v", err)
+		}
 	}
 
-	// Create output directory if it doesn't exist
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		log.Fatalf("Error creating output directory: %v", err)
+	// From here on, Ctrl-C stops the exports and removes their files
+	// instead of exiting right away
+	cleanupFiles.cancelOnSignal(interrupt)
+
+	// Bound the export time, if requested. The clock starts once the
+	// prompts are done.
+	if *maxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *maxDuration)
+		defer cancel()
 	}
 
-	// Create a wait group to handle concurrent exports
+	// Create a wait group for the export workers
 	var wg sync.WaitGroup
 	// Create an error channel to collect errors from goroutines
 	errChan := make(chan error, len(selectedTables))
+	// Collect the outcome of each table
+	var resultsMu sync.Mutex
+	var emptyTables, completed, partial, skipped []string
+	record := func(list *[]string, tableName string) {
+		resultsMu.Lock()
+		*list = append(*list, tableName)
+		resultsMu.Unlock()
+	}
+	recordDone := func(tableName string, stats exporter.Stats) {
+		record(&completed, tableName)
+		if stats.Rows == 0 {
+			record(&emptyTables, tableName)
+		}
+	}
+	// Collect the files of each table for -manifest
+	var results manifest
 
-	// Export each selected table
-	for _, table := range selectedTables {
-		wg.Add(1)
-		go func(tableName string) {
-			defer wg.Done()
+	// Export one selected table
+	exportTable := func(tableName string) error {
+		// Don't start tables once the deadline has passed
+		if ctx.Err() != nil {
+			record(&skipped, tableName)
+			results.add(tableName, nil, exporter.Stats{}, errors.New("not started before the deadline"))
+			return nil
+		}
+
+		// Get columns for the table
+		columns, err := database.GetColumns(db, config.Type, tableName)
+		if err != nil {
+			return fmt.Errorf("error getting columns for table %s: %v", tableName, err)
+		}
+
+		// Make sure the schema hasn't changed since selection
+		if *verifySchema {
+			added, removed := database.DiffColumns(selectedColumns[tableName], columns)
+			if len(added) > 0 || len(removed) > 0 {
+				return fmt.Errorf("schema of table %s changed since selection (added: %v, removed: %v)",
+					tableName, added, removed)
+			}
+		}
+
+		// Export the shared columns in the same order for every table
+		if sharedColumns != nil {
+			columns = sharedColumns
+		}
 
-			// Get columns for the table
-			columns, err := database.GetColumns(db, config.Type, tableName)
+		// Create exporter for the table
+		exp := exporter.NewTableExporter(db, tableName, columns, outputDir)
+		exp.QueryTemplate = *queryTemplate
+		exp.FileNameTemplate = *fileTemplate
+		exp.DatabaseName = dbName
+		exp.FileNameTime = exportTime
+		exp.Format = exporter.Format(*format)
+		exp.PrettyJSON = *prettyJSON
+		exp.Delimiter = csvDelimiter
+		exp.IncludeColumns = splitList(*columnsFlag)
+		if *columnsQuery != "" {
+			exp.IncludeColumns, err = database.ColumnsFromQuery(db, config.Type, *columnsQuery, tableName)
 			if err != nil {
-				errChan <- fmt.Errorf("error getting columns for table %s: %v", tableName, err)
+				return fmt.Errorf("error getting columns to export for table %s: %v", tableName, err)
+			}
+		}
+		exp.ExcludeColumns = splitList(*excludeColumns)
+		exp.Where = *where
+		exp.OrderBy = *orderBy
+		exp.Joins = joins[tableName]
+		exp.Limit = *limit
+		exp.TableSample = *tableSample
+		exp.View = isView[tableName]
+		exp.BoolFormat = bools
+		exp.BinaryEncoding = binaries
+		if schema != nil {
+			exp.Schema = schema.Tables[tableName]
+		}
+		exp.RepeatHeader = *repeatHeader
+		exp.MaxRowsPerFile = *maxRowsPerFile
+		exp.MaxBytesPerFile = int64(maxFileSize)
+		exp.TimeLayout = *timeLayout
+		exp.NullString = nulls.defaultToken(*nullString)
+		exp.ColumnNullStrings = nulls.forTable(tableName, columns)
+		exp.Compress = *compress
+		exp.IncrementalColumn = *incremental
+		exp.SkipBadRows = *skipBadRows
+		exp.ReadTimeout = *readTimeout
+		exp.RowHash = *rowHash
+		exp.BatchSize = *batchSizeFlag
+		exp.MemoryLimit = memoryLimit
+		nextProgress := int64(progressInterval)
+		var eta *etaEstimator
+		if total, ok := rowCounts[tableName]; ok {
+			if *limit > 0 {
+				total = min(total, int64(*limit))
+			}
+			eta = newETAEstimator(total)
+		}
+		exp.OnProgress = func(rowsWritten int64) {
+			var left time.Duration
+			estimated := false
+			if eta != nil {
+				left, estimated = eta.update(rowsWritten, time.Now())
+			}
+			if rowsWritten < nextProgress {
 				return
 			}
+			nextProgress = rowsWritten + progressInterval
+			if estimated {
+				infof("table %s: %d of %d rows, about %s left...\n",
+					tableName, rowsWritten, eta.total, left.Round(time.Second))
+			} else {
+				infof("table %s: %d rows...\n", tableName, rowsWritten)
+			}
+		}
+		exp.DBType = config.Type
+		exp.Dialect = config.Type
+		if dialect != "" {
+			exp.Dialect = dialect
+		}
+		if config.Type == database.MySQL || config.Type == database.MariaDB {
+			if err := applyMySQLColumnTypes(db, exp, tableName, columns); err != nil {
+				return fmt.Errorf("error getting column types for table %s: %v", tableName, err)
+			}
+		}
+		// Order the rows by the primary key unless told otherwise, so
+		// exports of the same data are identical
+		exp.OrderByPrimaryKey = !*unordered && *orderBy == "" && *incremental == ""
+		if *externalText > 0 || exp.OrderByPrimaryKey {
+			exp.ExternalTextThreshold = *externalText
+			exp.PrimaryKey, err = database.GetPrimaryKey(db, config.Type, tableName)
+			if err != nil && *externalText > 0 {
+				return fmt.Errorf("error getting primary key of table %s: %v", tableName, err)
+			}
+			if err != nil {
+				warnf("exporting table %s unordered, its primary key is unknown: %v\n", tableName, err)
+			}
+		}
+		if *commentHeader {
+			comments, err := database.GetColumnComments(db, config.Type, tableName)
+			if err != nil {
+				return fmt.Errorf("error getting column comments for table %s: %v", tableName, err)
+			}
+			if comments == nil {
+				warnf("%s has no column comments, writing a single header row for table %s\n",
+					config.Type, tableName)
+			}
+			exp.ColumnComments = comments
+		}
+		if *castText {
+			if err := applyTextCasts(exp, config.Type, columns); err != nil {
+				return fmt.Errorf("error casting columns of table %s: %v", tableName, err)
+			}
+		}
 
-			// Create exporter for the table
-			exp := exporter.NewTableExporter(db, tableName, columns, outputDir)
+		// Show the queries built from user input, and with -v every query
+		if query, err := exp.Query(); err == nil {
+			if *where != "" || *orderBy != "" {
+				infof("Exporting table %s with query: %s\n", tableName, query)
+			} else {
+				verbosef("Exporting table %s with query: %s\n", tableName, query)
+			}
+		}
 
-			// Export the table
-			if err := exp.Export(); err != nil {
-				errChan <- fmt.Errorf("error exporting table %s: %v", tableName, err)
-				return
+		if loader != nil {
+			if err := exp.ExportToContext(ctx, loader.TableWriter(tableName)); err != nil {
+				if ctx.Err() != nil {
+					warnf("stopped loading table %s after %d rows: %v\n",
+						tableName, exp.Stats().Rows, ctx.Err())
+					record(&partial, tableName)
+					return nil
+				}
+				return fmt.Errorf("error loading table %s into DuckDB: %v", tableName, err)
+			}
+			infof("Successfully loaded table %s into %s: %s\n", tableName, *toDuckDB, summary(exp.Stats()))
+			printStats(tableName, columns, exp.Stats())
+			recordDone(tableName, exp.Stats())
+			return nil
+		}
+
+		if *toStdout {
+			if err := exp.ExportStream(ctx, os.Stdout); err != nil {
+				if ctx.Err() != nil {
+					warnf("stopped exporting table %s after %d rows: %v\n",
+						tableName, exp.Stats().Rows, ctx.Err())
+					record(&partial, tableName)
+					return nil
+				}
+				return fmt.Errorf("error exporting table %s: %v", tableName, err)
 			}
+			infof("Successfully exported table %s to stdout: %s\n", tableName, summary(exp.Stats()))
+			printStats(tableName, columns, exp.Stats())
+			recordDone(tableName, exp.Stats())
+			return nil
+		}
+
+		// The export is written to a temporary file until it is done. An
+		// interrupted export removes it and keeps the previous file, while
+		// one stopped by -max-duration keeps its rows.
+		exp.OnTempFile = cleanupFiles.add
+		err = exp.ExportContext(ctx)
 
-			fmt.Printf("Successfully exported table %s to %s\n",
-				tableName, filepath.Join(outputDir, tableName+".csv"))
-		}(table)
+		// Export the table
+		if err != nil {
+			if ctx.Err() != nil {
+				warnf("stopped exporting table %s after %d rows: %v\n",
+					tableName, exp.Stats().Rows, ctx.Err())
+				record(&partial, tableName)
+				results.add(tableName, exp.OutputPaths(), exp.Stats(),
+					fmt.Errorf("stopped after %d rows: %v", exp.Stats().Rows, ctx.Err()))
+				return nil
+			}
+			if errors.Is(err, syscall.EMFILE) {
+				err = fmt.Errorf("%w; export fewer tables at once with -concurrency or raise the limit with ulimit -n", err)
+			}
+			return fmt.Errorf("error exporting table %s: %v", tableName, err)
+		}
+
+		if paths := exp.OutputPaths(); len(paths) > 1 {
+			infof("Successfully exported table %s to %d files, %s to %s: %s\n",
+				tableName, len(paths), paths[0], paths[len(paths)-1], summary(exp.Stats()))
+		} else {
+			infof("Successfully exported table %s to %s: %s\n", tableName, paths[0], summary(exp.Stats()))
+		}
+		printStats(tableName, columns, exp.Stats())
+		recordDone(tableName, exp.Stats())
+		results.add(tableName, exp.OutputPaths(), exp.Stats(), nil)
+		return nil
+	}
+
+	// Export the selected tables, at most -concurrency at once
+	tables := make(chan string)
+	for range min(*concurrency, len(selectedTables)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for tableName := range tables {
+				if err := exportTable(tableName); err != nil {
+					errChan <- err
+					results.add(tableName, nil, exporter.Stats{}, err)
+				}
+			}
+		}()
+	}
+	for _, table := range selectedTables {
+		tables <- table
 	}
+	close(tables)
 
 	// Wait for all exports to complete
 	wg.Wait()
 	close(errChan)
 
+	if errors.Is(context.Cause(ctx), errInterrupted) {
+		infof("Export interrupted, removing partial files\n")
+		cleanupFiles.run()
+		return 130
+	}
+
 	// Check for any errors
 	hasErrors := false
 	for err := range errChan {
 		if err != nil {
 			hasErrors = true
-			log.Printf("Error during export: %v\n", err)
+			errorf("export failed: %v\n", err)
+		}
+	}
+
+	if *maxDuration > 0 {
+		printDeadlineSummary(completed, partial, skipped)
+	}
+
+	if *manifestFlag {
+		if err := results.write(outputDir); err != nil {
+			errorf("%v\n", err)
+			return 1
+		}
+	}
+
+	if hasErrors {
+		return 1
+	}
+
+	if *requireNonEmpty && len(emptyTables) > 0 {
+		sort.Strings(emptyTables)
+		errorf("tables exported no rows: %s\n", strings.Join(emptyTables, ", "))
+		return 1
+	}
+
+	if len(partial) == 0 && len(skipped) == 0 {
+		infof("\nAll tables exported successfully!\n")
+	}
+
+	return 0
+}
+
+// databaseName returns the name of the exported database for {db} in
+// -filename-template: the file name of a SQLite database or dump, without
+// extension, or the name of the server database
+func databaseName(db *sql.DB, config database.Config) (string, error) {
+	fileName := func(path string) string {
+		name := strings.TrimSuffix(filepath.Base(path), ".gz")
+		return strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	switch {
+	case *dumpFile == database.StdinDump:
+		return "stdin", nil
+	case *dumpFile != "":
+		return fileName(*dumpFile), nil
+	case config.DBName != "":
+		return config.DBName, nil
+	case config.Type == database.SQLite:
+		return fileName(cmp.Or(config.FilePath, config.ConnectionURL)), nil
+	}
+	return database.CurrentDatabase(db, config.Type)
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
 	}
+	return items
+}
+
+// parseDelimiter converts the -delimiter flag value to a rune
+func parseDelimiter(value string) (rune, error) {
+	switch value {
+	case `\t`, "tab":
+		return '\t', nil
+	}
+	if utf8.RuneCountInString(value) != 1 {
+		return 0, fmt.Errorf("delimiter must be a single character, got %q", value)
+	}
+	r, _ := utf8.DecodeRuneInString(value)
+	return r, nil
+}
+
+// parseByteSize parses a size such as 512MB or 2GB. A number without a unit
+// is in bytes; units are powers of 1024.
+func parseByteSize(value string) (uint64, error) {
+	units := []struct {
+		suffix string
+		size   uint64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
 
-	if !hasErrors {
-		fmt.Println("\nAll tables exported successfully!")
+	number, multiplier := strings.ToUpper(strings.TrimSpace(value)), uint64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(number, unit.suffix) {
+			number, multiplier = strings.TrimSpace(strings.TrimSuffix(number, unit.suffix)), unit.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseUint(number, 10, 64)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("invalid size %q, want e.g. 512MB", value)
+	}
+	return n * multiplier, nil
+}
+
+// applyMySQLColumnTypes validates JSON columns and, with -mysql-geom-as-wkt,
+// converts spatial columns to WKT on the server
+func applyMySQLColumnTypes(db *sql.DB, exp *exporter.TableExporter, tableName string, columns []string) error {
+	types, err := database.GetColumnTypes(db, database.MySQL, tableName)
+	if err != nil {
+		return err
+	}
+
+	exp.Expressions = make(map[string]string)
+	for _, column := range columns {
+		switch typ := types[column]; {
+		case typ == "json":
+			exp.JSONColumns = append(exp.JSONColumns, column)
+		case *mysqlGeomAsWKT && database.IsMySQLGeometryType(typ):
+			quoted := database.QuoteIdentifier(database.MySQL, column)
+			exp.Expressions[column] = fmt.Sprintf("ST_AsText(%s) AS %s", quoted, quoted)
+		}
+	}
+	return nil
+}
+
+// applyTextCasts selects every column without a custom expression through a
+// CAST to the engine's text type
+func applyTextCasts(exp *exporter.TableExporter, dbType database.DBType, columns []string) error {
+	if exp.Expressions == nil {
+		exp.Expressions = make(map[string]string)
+	}
+	for _, column := range columns {
+		if _, ok := exp.Expressions[column]; ok {
+			continue
+		}
+		expr, err := database.CastToText(dbType, column)
+		if err != nil {
+			return err
+		}
+		exp.Expressions[column] = expr
+	}
+	return nil
+}
+
+// printDeadlineSummary reports which tables finished within -max-duration
+func printDeadlineSummary(completed, partial, skipped []string) {
+	fmt.Fprintln(status)
+	for _, group := range []struct {
+		label  string
+		tables []string
+	}{
+		{"Completed", completed},
+		{"Partial", partial},
+		{"Skipped", skipped},
+	} {
+		sort.Strings(group.tables)
+		fmt.Fprintf(status, "%s tables (%d): %s\n", group.label, len(group.tables), strings.Join(group.tables, ", "))
+	}
+}
+
+// summary describes the rows and time of an export, e.g. "12345 rows in 1.2s"
+func summary(stats exporter.Stats) string {
+	rows := fmt.Sprintf("%d rows", stats.Rows)
+	if stats.Rows == 1 {
+		rows = "1 row"
+	}
+	elapsed := stats.Elapsed.Round(time.Millisecond)
+	if elapsed >= time.Second {
+		elapsed = stats.Elapsed.Round(100 * time.Millisecond)
+	}
+	return fmt.Sprintf("%s in %s", rows, elapsed)
+}
+
+// printStats reports skipped rows and the columns that contained NULLs
+func printStats(tableName string, columns []string, stats exporter.Stats) {
+	if stats.SkippedRows > 0 {
+		warnf("skipped %d unreadable rows in table %s\n", stats.SkippedRows, tableName)
+	}
+
+	var nulls []string
+	for _, column := range columns {
+		if count := stats.NullCounts[column]; count > 0 {
+			nulls = append(nulls, fmt.Sprintf("%s=%d", column, count))
+		}
+	}
+	if len(nulls) > 0 {
+		infof("  NULL values in %s: %s\n", tableName, strings.Join(nulls, ", "))
 	}
 }
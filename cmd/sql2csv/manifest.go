@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sql2csv/pkg/exporter"
+	"strings"
+	"sync"
+)
+
+// manifestName is the file -manifest writes to the output directory
+const manifestName = "manifest.json"
+
+// manifestTable is the record of one selected table in the manifest
+type manifestTable struct {
+	Table string   `json:"table"`
+	Files []string `json:"files"` // relative to the output directory
+	Rows  int64    `json:"rows"`
+	Bytes int64    `json:"bytes"`
+
+	// NullCounts counts the NULLs written in each column
+	NullCounts map[string]int64 `json:"null_counts"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// manifest collects the outcome of every table for -manifest
+type manifest struct {
+	mu     sync.Mutex
+	tables []manifestTable
+}
+
+// add records the files, rows and NULLs a table's export wrote, and the
+// error that ended it early, if any
+func (m *manifest) add(tableName string, paths []string, stats exporter.Stats, err error) {
+	entry := manifestTable{
+		Table:      tableName,
+		Files:      []string{},
+		Rows:       stats.Rows,
+		NullCounts: stats.NullCounts,
+	}
+	if entry.NullCounts == nil {
+		entry.NullCounts = map[string]int64{}
+	}
+	for _, path := range paths {
+		info, statErr := os.Stat(path)
+		if statErr != nil {
+			continue // the export was never committed
+		}
+		entry.Files = append(entry.Files, filepath.Base(path))
+		entry.Bytes += info.Size()
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tables = append(m.tables, entry)
+}
+
+// write writes the manifest to dir, listing the tables by name
+func (m *manifest) write(dir string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	slices.SortFunc(m.tables, func(a, b manifestTable) int {
+		return strings.Compare(a.Table, b.Table)
+	})
+	for _, table := range m.tables {
+		if slices.Contains(table.Files, manifestName) {
+			return fmt.Errorf("error writing %s: it would replace the export of table %s", manifestName, table.Table)
+		}
+	}
+
+	data, err := json.MarshalIndent(struct {
+		Tables []manifestTable `json:"tables"`
+	}{m.tables}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding %s: %w", manifestName, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, manifestName), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing %s: %w", manifestName, err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// nullFlag collects the -null flags, each KEY=TOKEN where KEY is
+// table.column, column or default
+type nullFlag []string
+
+func (f *nullFlag) String() string {
+	return strings.Join(*f, " ")
+}
+
+func (f *nullFlag) Set(value string) error {
+	if !strings.Contains(value, "=") {
+		return fmt.Errorf("expected table.column=token, column=token or default=token, got %q", value)
+	}
+	*f = append(*f, value)
+	return nil
+}
+
+// nullTokens holds the NULL replacements given with -null
+type nullTokens struct {
+	def     *string
+	columns map[string]string // by column, for every table
+	tables  map[string]string // by table.column
+}
+
+// parseNullTokens parses the -null flags. Later flags win over earlier ones
+// for the same key.
+func parseNullTokens(values []string) nullTokens {
+	tokens := nullTokens{columns: make(map[string]string), tables: make(map[string]string)}
+	for _, value := range values {
+		key, token, _ := strings.Cut(value, "=")
+		switch {
+		case key == "default":
+			tokens.def = &token
+		case strings.Contains(key, "."):
+			tokens.tables[key] = token
+		default:
+			tokens.columns[key] = token
+		}
+	}
+	return tokens
+}
+
+// defaultToken returns the NULL token of columns without their own, which
+// falls back to -null-string
+func (n nullTokens) defaultToken(nullString string) string {
+	if n.def != nil {
+		return *n.def
+	}
+	return nullString
+}
+
+// forTable returns the per-column NULL tokens of a table. Table-qualified
+// entries win over bare column names.
+func (n nullTokens) forTable(table string, columns []string) map[string]string {
+	tokens := make(map[string]string)
+	for _, column := range columns {
+		if token, ok := n.columns[column]; ok {
+			tokens[column] = token
+		}
+		if token, ok := n.tables[table+"."+column]; ok {
+			tokens[column] = token
+		}
+	}
+	return tokens
+}
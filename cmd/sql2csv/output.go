@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// status receives progress messages; it is stderr when the export itself is
+// written to stdout
+var status io.Writer = os.Stdout
+
+// infof prints a progress or success message, unless -q is given
+func infof(format string, args ...interface{}) {
+	if !*quiet {
+		fmt.Fprintf(status, format, args...)
+	}
+}
+
+// verbosef prints the details only -v asks for, such as export queries
+func verbosef(format string, args ...interface{}) {
+	if *verbose {
+		fmt.Fprintf(status, format, args...)
+	}
+}
+
+// warnf prints a warning, even with -q
+func warnf(format string, args ...interface{}) {
+	fmt.Fprintf(status, "Warning: "+format, args...)
+}
+
+// errorf logs an error to stderr, even with -q
+func errorf(format string, args ...interface{}) {
+	log.Printf("Error: "+format, args...)
+}
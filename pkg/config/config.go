@@ -0,0 +1,184 @@
+// Package config reads connection and export settings from a YAML or JSON
+// file, so they don't have to be entered on every run
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sql2csv/pkg/database"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File holds the settings of a config file. Keys are named after the
+// command-line flags they stand for:
+//
+//	database:
+//	  type: postgres
+//	  host: db.example.com
+//	  user: reporting
+//	  dbname: shop
+//	tables: [customers, orders]
+//	output: ./export
+//	export:
+//	  format: csv
+//	  delimiter: ";"
+//	  null-string: \N
+//	  gzip: true
+type File struct {
+	Database Database `json:"database" yaml:"database"`
+	Tables   []string `json:"tables" yaml:"tables"`
+	Output   string   `json:"output" yaml:"output"`
+	Export   Export   `json:"export" yaml:"export"`
+}
+
+// Database holds the connection settings of a config file
+type Database struct {
+	Type string `json:"type" yaml:"type"`
+	Host string `json:"host" yaml:"host"`
+	Port int    `json:"port" yaml:"port"`
+	User string `json:"user" yaml:"user"`
+	// Socket is the Unix socket of a local MySQL or PostgreSQL server
+	Socket string `json:"socket" yaml:"socket"`
+	// Password is better left to $SQL2CSV_DB_PASSWORD than stored in a file
+	Password string `json:"password" yaml:"password"`
+	DBName   string `json:"dbname" yaml:"dbname"` // database name, or the file path for SQLite
+	Conn     string `json:"conn" yaml:"conn"`     // connection string
+	SSLMode  string `json:"sslmode" yaml:"sslmode"`
+	Schema   string `json:"schema" yaml:"schema"`
+}
+
+// Export holds the export options of a config file
+type Export struct {
+	Format          string   `json:"format" yaml:"format"`
+	FileTemplate    string   `json:"filename-template" yaml:"filename-template"`
+	Delimiter       string   `json:"delimiter" yaml:"delimiter"`
+	NullString      string   `json:"null-string" yaml:"null-string"`
+	BoolFormat      string   `json:"bool-format" yaml:"bool-format"`
+	BinaryEncoding  string   `json:"binary-encoding" yaml:"binary-encoding"`
+	TimeLayout      string   `json:"time-layout" yaml:"time-layout"`
+	Gzip            bool     `json:"gzip" yaml:"gzip"`
+	Manifest        bool     `json:"manifest" yaml:"manifest"`
+	PrettyJSON      bool     `json:"pretty-json" yaml:"pretty-json"`
+	Columns         []string `json:"columns" yaml:"columns"`
+	ExcludeColumns  []string `json:"exclude-columns" yaml:"exclude-columns"`
+	Where           string   `json:"where" yaml:"where"`
+	OrderBy         string   `json:"order-by" yaml:"order-by"`
+	Limit           int      `json:"limit" yaml:"limit"`
+	BatchSize       int      `json:"batch-size" yaml:"batch-size"`
+	MaxRowsPerFile  int      `json:"max-rows-per-file" yaml:"max-rows-per-file"`
+	MaxBytesPerFile string   `json:"max-bytes-per-file" yaml:"max-bytes-per-file"`
+}
+
+// Load reads a config file, as JSON if its name ends in .json and as YAML
+// otherwise. Unknown keys are an error, so misspelt settings aren't
+// silently ignored.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %w", err)
+	}
+
+	var file File
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&file)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err = decoder.Decode(&file); errors.Is(err, io.EOF) {
+			err = nil // an empty file sets nothing
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+	if file.Database.Port < 0 || file.Database.Port > 65535 {
+		return nil, fmt.Errorf("invalid port in config file %s: %d", path, file.Database.Port)
+	}
+	return &file, nil
+}
+
+// Config returns the connection settings as a database.Config
+func (d Database) Config() database.Config {
+	config := database.Config{
+		Type:          database.DBType(d.Type),
+		Host:          d.Host,
+		Socket:        d.Socket,
+		Port:          d.Port,
+		User:          d.User,
+		Password:      d.Password,
+		DBName:        d.DBName,
+		ConnectionURL: d.Conn,
+		SSLMode:       d.SSLMode,
+		Schema:        d.Schema,
+	}
+	if config.Type == database.SQLite {
+		config.FilePath, config.DBName = d.DBName, ""
+	}
+	return config
+}
+
+// Flags returns the settings the file gives, keyed by the name of the
+// command-line flag they stand for, in the form the flag parses. The
+// password has no flag and is left out.
+func (f *File) Flags() map[string]string {
+	flags := make(map[string]string)
+	set := func(name, value string) {
+		if value != "" {
+			flags[name] = value
+		}
+	}
+	setInt := func(name string, value int) {
+		if value != 0 {
+			flags[name] = strconv.Itoa(value)
+		}
+	}
+
+	set("type", f.Database.Type)
+	set("host", f.Database.Host)
+	set("socket", f.Database.Socket)
+	setInt("port", f.Database.Port)
+	set("user", f.Database.User)
+	set("dbname", f.Database.DBName)
+	set("conn", f.Database.Conn)
+	set("sslmode", f.Database.SSLMode)
+	set("schema", f.Database.Schema)
+	set("tables", strings.Join(f.Tables, ","))
+	set("output", f.Output)
+
+	e := f.Export
+	set("format", e.Format)
+	set("filename-template", e.FileTemplate)
+	set("delimiter", e.Delimiter)
+	set("null-string", e.NullString)
+	set("bool-format", e.BoolFormat)
+	set("binary-encoding", e.BinaryEncoding)
+	set("time-layout", e.TimeLayout)
+	if e.Gzip {
+		flags["gzip"] = "true"
+	}
+	if e.Manifest {
+		flags["manifest"] = "true"
+	}
+	if e.PrettyJSON {
+		flags["pretty-json"] = "true"
+	}
+	set("columns", strings.Join(e.Columns, ","))
+	set("exclude-columns", strings.Join(e.ExcludeColumns, ","))
+	set("where", e.Where)
+	set("order-by", e.OrderBy)
+	setInt("limit", e.Limit)
+	setInt("batch-size", e.BatchSize)
+	setInt("max-rows-per-file", e.MaxRowsPerFile)
+	set("max-bytes-per-file", e.MaxBytesPerFile)
+	return flags
+}
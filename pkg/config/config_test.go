@@ -0,0 +1,140 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sql2csv/pkg/database"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	want := &File{
+		Database: Database{Type: "postgres", Host: "db.example.com", Port: 5433, User: "reporting", DBName: "shop"},
+		Tables:   []string{"customers", "orders"},
+		Output:   "./export",
+		Export:   Export{Format: "csv", Delimiter: ";", NullString: `\N`, Gzip: true},
+	}
+
+	tests := []struct {
+		name     string
+		filename string
+		content  string
+		want     *File
+		wantErr  bool
+	}{
+		{
+			name:     "YAML",
+			filename: "sql2csv.yaml",
+			content: `database:
+  type: postgres
+  host: db.example.com
+  port: 5433
+  user: reporting
+  dbname: shop
+tables: [customers, orders]
+output: ./export
+export:
+  format: csv
+  delimiter: ";"
+  null-string: \N
+  gzip: true
+`,
+			want: want,
+		},
+		{
+			name:     "JSON",
+			filename: "sql2csv.json",
+			content: `{"database": {"type": "postgres", "host": "db.example.com", "port": 5433, "user": "reporting", "dbname": "shop"},
+				"tables": ["customers", "orders"], "output": "./export",
+				"export": {"format": "csv", "delimiter": ";", "null-string": "\\N", "gzip": true}}`,
+			want: want,
+		},
+		{name: "Empty YAML", filename: "empty.yml", content: "# nothing yet\n", want: &File{}},
+		{name: "Unknown YAML key", filename: "typo.yaml", content: "export:\n  fromat: json\n", wantErr: true},
+		{name: "Unknown JSON key", filename: "typo.json", content: `{"tabels": ["users"]}`, wantErr: true},
+		{name: "Invalid port", filename: "port.yaml", content: "database:\n  port: 70000\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.filename)
+			if err := os.WriteFile(path, []byte(tt.content), 0666); err != nil {
+				t.Fatalf("Failed to write config file: %v", err)
+			}
+			got, err := Load(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Load() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFile_Flags(t *testing.T) {
+	file := &File{
+		Database: Database{Type: "postgres", Port: 5433, Socket: "/tmp/.s.PGSQL.5433", User: "app", Password: "secret", DBName: "shop", SSLMode: "require", Schema: "sales"},
+		Tables:   []string{"users", "orders"},
+		Export:   Export{Format: "json", BinaryEncoding: "hex", Columns: []string{"id", "name"}, Limit: 10, Gzip: true, Manifest: true, PrettyJSON: true},
+	}
+	want := map[string]string{
+		"type":            "postgres",
+		"port":            "5433",
+		"socket":          "/tmp/.s.PGSQL.5433",
+		"sslmode":         "require",
+		"schema":          "sales",
+		"user":            "app",
+		"dbname":          "shop",
+		"tables":          "users,orders",
+		"format":          "json",
+		"binary-encoding": "hex",
+		"columns":         "id,name",
+		"limit":           "10",
+		"gzip":            "true",
+		"manifest":        "true",
+		"pretty-json":     "true",
+	}
+	if got := file.Flags(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Flags() = %v, want %v", got, want)
+	}
+}
+
+func TestDatabase_Config(t *testing.T) {
+	tests := []struct {
+		name string
+		db   Database
+		want database.Config
+	}{
+		{
+			name: "Server",
+			db:   Database{Type: "postgres", Host: "db", Port: 5432, User: "u", Password: "p", DBName: "shop", SSLMode: "verify-full"},
+			want: database.Config{Type: database.Postgres, Host: "db", Port: 5432, User: "u", Password: "p", DBName: "shop", SSLMode: "verify-full"},
+		},
+		{
+			name: "SQLite file",
+			db:   Database{Type: "sqlite3", DBName: "./shop.db"},
+			want: database.Config{Type: database.SQLite, FilePath: "./shop.db"},
+		},
+		{
+			name: "Unix socket",
+			db:   Database{Type: "postgres", Socket: "/var/run/postgresql", User: "u", DBName: "shop"},
+			want: database.Config{Type: database.Postgres, Socket: "/var/run/postgresql", User: "u", DBName: "shop"},
+		},
+		{
+			name: "Connection string",
+			db:   Database{Type: "mysql", Conn: "u:p@tcp(db:3306)/shop"},
+			want: database.Config{Type: database.MySQL, ConnectionURL: "u:p@tcp(db:3306)/shop"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.db.Config(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Config() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
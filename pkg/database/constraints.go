@@ -0,0 +1,130 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// addConstraintPattern matches the ALTER TABLE ... ADD CONSTRAINT statements
+// pg_dump writes for keys and checks, capturing the table and the constraint
+var addConstraintPattern = regexp.MustCompile(`(?is)^ALTER TABLE\s+(?:ONLY\s+)?(?:IF EXISTS\s+)?(\S+)\s+ADD\s+(CONSTRAINT\s+\S+\s+(?:PRIMARY KEY|UNIQUE|FOREIGN KEY|CHECK)\b.*?)\s*;?\s*$`)
+
+// schemaPrefixPattern matches the schema qualification of referenced tables
+var schemaPrefixPattern = regexp.MustCompile(`\bpublic\.`)
+
+// notValidPattern matches PostgreSQL's NOT VALID, which SQLite lacks
+var notValidPattern = regexp.MustCompile(`(?i)\s+NOT VALID$`)
+
+// tableConstraints are the constraints added to one table with ALTER TABLE
+type tableConstraints struct {
+	table      string
+	clauses    []string
+	statements []string
+}
+
+// deferConstraint holds back an ALTER TABLE ... ADD CONSTRAINT statement,
+// which SQLite doesn't support, so applyConstraints can rebuild the table
+// with it. It reports whether the statement was held back.
+func (p *SQLDumpParser) deferConstraint(stmt string) bool {
+	if !p.keepConstraints {
+		return false
+	}
+	m := addConstraintPattern.FindStringSubmatch(stmt)
+	if m == nil {
+		return false
+	}
+
+	table := strings.Trim(strings.TrimPrefix(m[1], "public."), "\"`")
+	clause := schemaPrefixPattern.ReplaceAllString(m[2], "")
+	clause = notValidPattern.ReplaceAllString(clause, "")
+
+	for i := range p.constraints {
+		if p.constraints[i].table == table {
+			p.constraints[i].clauses = append(p.constraints[i].clauses, clause)
+			p.constraints[i].statements = append(p.constraints[i].statements, stmt)
+			return true
+		}
+	}
+	p.constraints = append(p.constraints, tableConstraints{
+		table:      table,
+		clauses:    []string{clause},
+		statements: []string{stmt},
+	})
+	return true
+}
+
+// applyConstraints rebuilds each table that had constraints held back with
+// them in its definition. A table whose rows violate a constraint is left as
+// it was and its constraint statements count as failed.
+func (p *SQLDumpParser) applyConstraints(db *sql.DB) {
+	for _, c := range p.constraints {
+		if err := rebuildTable(db, c.table, c.clauses); err != nil {
+			p.failed = append(p.failed, c.statements...)
+			p.logDebug("Warning: Failed to add constraints to table %s: %v\n", c.table, err)
+			continue
+		}
+		p.summary.Executed += len(c.statements)
+	}
+	p.constraints = nil
+}
+
+// rebuildTable recreates a SQLite table with extra table constraints, the
+// way SQLite suggests for schema changes ALTER TABLE can't make: create the
+// new table, copy the rows, drop the old table and rename the new one. The
+// table's indexes are created again afterwards.
+func rebuildTable(db *sql.DB, table string, clauses []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var createSQL string
+	err = tx.QueryRow("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&createSQL)
+	if err != nil {
+		return fmt.Errorf("error reading definition of table %s: %w", table, err)
+	}
+	open, end := strings.Index(createSQL, "("), strings.LastIndex(createSQL, ")")
+	if open < 0 || end < open {
+		return fmt.Errorf("unexpected definition of table %s: %s", table, createSQL)
+	}
+
+	rows, err := tx.Query("SELECT sql FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND sql IS NOT NULL", table)
+	if err != nil {
+		return fmt.Errorf("error reading indexes of table %s: %w", table, err)
+	}
+	var indexes []string
+	for rows.Next() {
+		var index string
+		if err := rows.Scan(&index); err != nil {
+			rows.Close()
+			return fmt.Errorf("error reading indexes of table %s: %w", table, err)
+		}
+		indexes = append(indexes, index)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error reading indexes of table %s: %w", table, err)
+	}
+
+	// Renaming the old table away would also rewrite the foreign keys that
+	// reference it, so the new table is the one renamed
+	quoted := QuoteIdentifier(SQLite, table)
+	tmp := QuoteIdentifier(SQLite, table+"__constraints")
+	statements := []string{
+		fmt.Sprintf("CREATE TABLE %s %s, %s%s",
+			tmp, strings.TrimSpace(createSQL[open:end]), strings.Join(clauses, ", "), createSQL[end:]),
+		fmt.Sprintf("INSERT INTO %s SELECT * FROM %s", tmp, quoted),
+		fmt.Sprintf("DROP TABLE %s", quoted),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", tmp, quoted),
+	}
+	statements = append(statements, indexes...)
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -1,13 +1,24 @@
 package database
 
 import (
+	"cmp"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/uber/athenadriver/go"
 )
 
 type DBType string
@@ -16,8 +27,48 @@ const (
 	MySQL    DBType = "mysql"
 	Postgres DBType = "postgres"
 	SQLite   DBType = "sqlite3"
+	Athena   DBType = "awsathena"
+
+	// MariaDB is wire-compatible with MySQL and uses its driver and queries
+	MariaDB DBType = "mariadb"
+
+	// SQLServer is only a target dialect of the sql format; sql2csv has no
+	// SQL Server driver to export from it
+	SQLServer DBType = "sqlserver"
 )
 
+// Types returns the database types sql2csv can connect to
+func Types() []DBType {
+	return []DBType{MySQL, MariaDB, Postgres, SQLite, Athena}
+}
+
+// CheckType returns an error if sql2csv cannot connect to dbType
+func CheckType(dbType DBType) error {
+	if !slices.Contains(Types(), dbType) {
+		return fmt.Errorf("unsupported database type: %s", dbType)
+	}
+	return nil
+}
+
+// DefaultSSLMode is the PostgreSQL sslmode of connections built from
+// individual fields when Config.SSLMode is empty
+const DefaultSSLMode = "disable"
+
+// SSLModes are the PostgreSQL sslmode values the driver supports
+var SSLModes = []string{"disable", "require", "verify-ca", "verify-full"}
+
+// DefaultSchema is the PostgreSQL schema of connections built from
+// individual fields when Config.Schema is empty
+const DefaultSchema = "public"
+
+// DefaultMaxOpenConns is the connection limit of a Config without
+// MaxOpenConns, so parallel table exports don't overwhelm the server
+const DefaultMaxOpenConns = 8
+
+// DefaultConnectTimeout is how long Connect waits for the database to answer
+// when Config.ConnectTimeout is zero
+const DefaultConnectTimeout = 5 * time.Second
+
 type Config struct {
 	Type          DBType
 	Host          string
@@ -27,10 +78,62 @@ type Config struct {
 	DBName        string
 	FilePath      string // For SQLite
 	ConnectionURL string // For direct connection string/URL support
+
+	// Socket is the Unix socket MySQL and PostgreSQL connections built from
+	// the individual fields use instead of Host. For PostgreSQL it is the
+	// directory holding the socket, e.g. /var/run/postgresql, whose file is
+	// chosen by Port, or the socket file itself.
+	Socket string
+
+	// SSLMode is the sslmode of PostgreSQL connections built from the
+	// individual fields, one of SSLModes; empty means DefaultSSLMode. A
+	// ConnectionURL sets its own.
+	SSLMode string
+
+	// Schema is the PostgreSQL schema whose tables are listed and exported;
+	// empty means DefaultSchema. It becomes the connection's search_path, so
+	// unqualified table names resolve in it. A ConnectionURL sets its own
+	// search_path, or has the server's default.
+	Schema string
+
+	// MaxOpenConns limits the open connections; zero means
+	// DefaultMaxOpenConns and a negative value no limit. Exports beyond the
+	// limit wait for a connection to be free.
+	MaxOpenConns int
+
+	// MaxIdleConns is how many connections are kept open while unused; zero
+	// means as many as MaxOpenConns and a negative value none
+	MaxIdleConns int
+
+	// ConnMaxLifetime, when greater than zero, closes connections once they
+	// have been open this long, after their current query
+	ConnMaxLifetime time.Duration
+
+	// ConnectTimeout is how long Connect waits for the database to answer
+	// its connection check; zero means DefaultConnectTimeout and a negative
+	// value no limit
+	ConnectTimeout time.Duration
+
+	// DB, when set, is an open database, e.g. a dump imported into memory,
+	// that Connect returns instead of opening a connection
+	DB *sql.DB
 }
 
 // Connect establishes a database connection based on the provided configuration
 func Connect(config Config) (*sql.DB, error) {
+	return ConnectContext(context.Background(), config)
+}
+
+// ConnectContext is like Connect but gives up on the connection check when
+// ctx is done
+func ConnectContext(ctx context.Context, config Config) (*sql.DB, error) {
+	if config.DB != nil {
+		if err := ping(ctx, config.DB, config); err != nil {
+			return nil, err
+		}
+		return config.DB, nil
+	}
+
 	var dsn string
 
 	if config.ConnectionURL != "" {
@@ -40,9 +143,10 @@ func Connect(config Config) (*sql.DB, error) {
 		// For PostgreSQL, handle SSL mode
 		if config.Type == Postgres {
 			// Try to connect with the original connection string first
-			db, err := sql.Open(string(config.Type), dsn)
+			db, err := sql.Open(config.Type.driver(), dsn)
 			if err == nil {
-				err = db.Ping()
+				setPoolLimits(db, config)
+				err = ping(ctx, db, config)
 				if err == nil {
 					return db, nil
 				}
@@ -51,7 +155,8 @@ func Connect(config Config) (*sql.DB, error) {
 
 			// If the error is SSL-related, modify the connection string and retry
 			if err != nil && strings.Contains(err.Error(), "SSL") {
-				fmt.Println("Warning: SSL connection failed. Retrying with SSL disabled...")
+				// Warn on stderr, which doesn't mix with -stdout exports
+				log.Printf("Warning: SSL connection failed. Retrying with SSL disabled...")
 				if strings.Contains(dsn, "?") {
 					dsn += "&sslmode=disable"
 				} else {
@@ -59,45 +164,374 @@ func Connect(config Config) (*sql.DB, error) {
 				}
 			}
 		}
+
+		// Athena reports NULLs as missing data, which the driver turns into
+		// an error unless asked to return nil
+		if config.Type == Athena && !strings.Contains(dsn, "missingAs") {
+			if strings.Contains(dsn, "?") {
+				dsn += "&missingAsNil=true"
+			} else {
+				dsn += "?missingAsNil=true"
+			}
+		}
 	} else {
 		// Otherwise, build the connection string from individual fields
+		if config.Socket != "" && config.Type != MySQL && config.Type != MariaDB && config.Type != Postgres {
+			return nil, fmt.Errorf("unix sockets are only supported for mysql, mariadb and postgres, not %s", config.Type)
+		}
 		switch config.Type {
-		case MySQL:
-			dsn = fmt.Sprintf("%s:%s@tcp(%s:%d)/%s",
-				config.User, config.Password, config.Host, config.Port, config.DBName)
+		case MySQL, MariaDB:
+			dsn = mysqlDSN(config)
 		case Postgres:
-			dsn = fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-				config.Host, config.Port, config.User, config.Password, config.DBName)
+			var err error
+			if dsn, err = postgresDSN(config); err != nil {
+				return nil, err
+			}
 		case SQLite:
 			dsn = config.FilePath
+		case Athena:
+			return nil, fmt.Errorf("athena connections require a connection string")
 		default:
 			return nil, fmt.Errorf("unsupported database type: %s", config.Type)
 		}
 	}
 
-	db, err := sql.Open(string(config.Type), dsn)
+	db, err := sql.Open(config.Type.driver(), dsn)
 	if err != nil {
 		return nil, fmt.Errorf("error connecting to database: %w", err)
 	}
+	setPoolLimits(db, config)
 
-	if err = db.Ping(); err != nil {
+	if err = ping(ctx, db, config); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("error pinging database: %w", err)
+		return nil, err
+	}
+
+	// A search_path naming no existing schema would list no tables
+	if config.Type == Postgres && config.ConnectionURL == "" {
+		var schema sql.NullString
+		if err := db.QueryRowContext(ctx, "SELECT current_schema()").Scan(&schema); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("error checking schema: %w", err)
+		}
+		if !schema.Valid {
+			db.Close()
+			return nil, fmt.Errorf("schema %s does not exist", cmp.Or(config.Schema, DefaultSchema))
+		}
 	}
 
 	return db, nil
 }
 
+// driver returns the name of the database/sql driver of a database type
+func (t DBType) driver() string {
+	if t == MariaDB {
+		return string(MySQL)
+	}
+	return string(t)
+}
+
+// ping checks that the database answers, giving up after the configured
+// ConnectTimeout so an unreachable server doesn't block forever
+func ping(ctx context.Context, db *sql.DB, config Config) error {
+	timeout := cmp.Or(config.ConnectTimeout, DefaultConnectTimeout)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	// Some drivers, like lib/pq, don't watch ctx until they have connected,
+	// so stop waiting for them when it is done
+	done := make(chan error, 1)
+	go func() { done <- db.PingContext(ctx) }()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("error pinging database at %s: no response within %s", config.address(), timeout)
+		}
+		return fmt.Errorf("error pinging database at %s: %w", config.address(), err)
+	}
+	return nil
+}
+
+// address describes the server or file a configuration connects to, for
+// error messages. Connection strings may hold a password and aren't shown.
+func (c Config) address() string {
+	switch {
+	case c.DB != nil:
+		return "the open database"
+	case c.FilePath != "":
+		return c.FilePath
+	case c.ConnectionURL != "":
+		return fmt.Sprintf("the %s connection string's server", c.Type)
+	case c.Socket != "":
+		return c.Socket
+	}
+	return fmt.Sprintf("%s:%d", c.Host, c.Port)
+}
+
+// setPoolLimits applies the connection pool settings of a configuration
+func setPoolLimits(db *sql.DB, config Config) {
+	maxOpen := config.MaxOpenConns
+	if maxOpen == 0 {
+		maxOpen = DefaultMaxOpenConns
+	}
+	db.SetMaxOpenConns(max(maxOpen, 0))
+
+	maxIdle := config.MaxIdleConns
+	if maxIdle == 0 {
+		// Keep every connection of a parallel export for the next table.
+		// Without a limit on open connections, keep the driver default.
+		maxIdle = maxOpen
+		if maxOpen < 0 {
+			maxIdle = 2
+		}
+	}
+	db.SetMaxIdleConns(max(maxIdle, 0))
+
+	db.SetConnMaxLifetime(max(config.ConnMaxLifetime, 0))
+}
+
+// mysqlDSN builds the connection string of a MySQL or MariaDB configuration
+func mysqlDSN(config Config) string {
+	address := fmt.Sprintf("tcp(%s:%d)", config.Host, config.Port)
+	if config.Socket != "" {
+		address = fmt.Sprintf("unix(%s)", config.Socket)
+	}
+	return fmt.Sprintf("%s:%s@%s/%s", config.User, config.Password, address, config.DBName)
+}
+
+// postgresDSN builds the key/value connection string of a PostgreSQL
+// configuration
+func postgresDSN(config Config) (string, error) {
+	sslMode := cmp.Or(config.SSLMode, DefaultSSLMode)
+	if !slices.Contains(SSLModes, sslMode) {
+		return "", fmt.Errorf("unsupported sslmode %q, want one of %s", sslMode, strings.Join(SSLModes, ", "))
+	}
+	// libpq takes the socket's directory as the host and finds the socket
+	// file, .s.PGSQL.<port>, by the port
+	host, port := config.Host, strconv.Itoa(config.Port)
+	if config.Socket != "" {
+		host = config.Socket
+		if dir, file := path.Split(config.Socket); strings.HasPrefix(file, ".s.PGSQL.") {
+			host, port = path.Clean(dir), strings.TrimPrefix(file, ".s.PGSQL.")
+		}
+		host = quoteDSNValue(host)
+	}
+	// search_path holds identifiers, so a schema named e.g. Sales must be
+	// quoted to keep its case
+	schema := QuoteIdentifier(Postgres, cmp.Or(config.Schema, DefaultSchema))
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s search_path=%s",
+		host, port, quoteDSNValue(config.User), quoteDSNValue(config.Password), quoteDSNValue(config.DBName),
+		sslMode, quoteDSNValue(schema)), nil
+}
+
+// quoteDSNValue quotes a value of a key/value PostgreSQL connection string
+func quoteDSNValue(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
+
+// QuoteIdentifier quotes a table or column name for use in a query so that
+// reserved words and unusual characters are handled correctly
+func QuoteIdentifier(dbType DBType, name string) string {
+	switch dbType {
+	case MySQL, MariaDB:
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	case SQLServer:
+		return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+	default:
+		return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+	}
+}
+
+// QuoteLiteral quotes a string literal, escaping it for the given database
+// type
+func QuoteLiteral(dbType DBType, s string) string {
+	if dbType == MySQL || dbType == MariaDB {
+		// MySQL treats backslashes as escape characters by default
+		s = strings.ReplaceAll(s, `\`, `\\`)
+	}
+	quoted := "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	if dbType == SQLServer {
+		// Without the N prefix SQL Server converts the literal to the
+		// database's code page, losing characters outside it
+		return "N" + quoted
+	}
+	return quoted
+}
+
+// ColumnsFromQuery runs a metadata query that returns column names in its
+// first column. A {table} placeholder is replaced with the table name as a
+// string literal.
+func ColumnsFromQuery(db *sql.DB, dbType DBType, query, tableName string) ([]string, error) {
+	query = strings.ReplaceAll(query, "{table}", QuoteLiteral(dbType, tableName))
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error running columns query: %w", err)
+	}
+	defer rows.Close()
+
+	// Extra result columns, e.g. the tag being filtered on, are ignored
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("error reading columns query result: %w", err)
+	}
+	var column sql.NullString
+	dest := []interface{}{&column}
+	for i := 1; i < len(names); i++ {
+		dest = append(dest, new(interface{}))
+	}
+
+	var columns []string
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("error scanning column name: %w", err)
+		}
+		if column.Valid && column.String != "" {
+			columns = append(columns, column.String)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("columns query returned no columns for table %s", tableName)
+	}
+	return columns, nil
+}
+
+// CastToText returns a SELECT expression that casts a column to the
+// engine's text type, aliased back to the column name
+func CastToText(dbType DBType, column string) (string, error) {
+	var textType string
+	switch dbType {
+	case MySQL, MariaDB:
+		// MySQL's CAST only accepts CHAR, not TEXT or VARCHAR
+		textType = "CHAR"
+	case Postgres, SQLite:
+		textType = "TEXT"
+	case Athena:
+		textType = "VARCHAR"
+	default:
+		return "", fmt.Errorf("unsupported database type: %s", dbType)
+	}
+
+	quoted := QuoteIdentifier(dbType, column)
+	return fmt.Sprintf("CAST(%s AS %s) AS %s", quoted, textType, quoted), nil
+}
+
+// RandomSample returns a WHERE predicate that keeps about percent percent of
+// the rows, chosen independently per row
+func RandomSample(dbType DBType, percent float64) (string, error) {
+	fraction := strconv.FormatFloat(percent/100, 'f', -1, 64)
+	switch dbType {
+	case MySQL, MariaDB:
+		return "RAND() < " + fraction, nil
+	case Postgres, Athena:
+		return "random() < " + fraction, nil
+	case SQLite:
+		// RANDOM() is a signed 64-bit integer
+		return "RANDOM() / 18446744073709551616.0 + 0.5 < " + fraction, nil
+	default:
+		return "", fmt.Errorf("unsupported database type: %s", dbType)
+	}
+}
+
+// DiffSideColumn is the column of a diff query naming the table each row
+// came from
+const DiffSideColumn = "_side"
+
+// DiffQuery builds a query returning the rows of each table that have no
+// identical row in the other, ordered by key. Rows whose key exists on both
+// sides with different values show up once per side. Column values are
+// compared NULL-safely.
+func DiffQuery(dbType DBType, tableA, tableB string, columns []string, key string) (string, error) {
+	var equal string
+	switch dbType {
+	case MySQL, MariaDB:
+		equal = "%s <=> %s"
+	case SQLite:
+		equal = "%s IS %s"
+	case Postgres, Athena:
+		equal = "%s IS NOT DISTINCT FROM %s"
+	default:
+		return "", fmt.Errorf("unsupported database type: %s", dbType)
+	}
+
+	found := false
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = QuoteIdentifier(dbType, column)
+		found = found || column == key
+	}
+	if !found {
+		return "", fmt.Errorf("key column %s is not one of the compared columns", key)
+	}
+
+	// Rows of from without a matching row in other
+	side := func(from, other string) string {
+		conditions := make([]string, len(quoted))
+		selected := make([]string, len(quoted))
+		for i, column := range quoted {
+			conditions[i] = fmt.Sprintf(equal, "o."+column, "f."+column)
+			selected[i] = "f." + column
+		}
+		return fmt.Sprintf("SELECT %s AS %s, %s FROM %s f WHERE NOT EXISTS (SELECT 1 FROM %s o WHERE %s)",
+			QuoteLiteral(dbType, from), QuoteIdentifier(dbType, DiffSideColumn),
+			strings.Join(selected, ", "), QuoteIdentifier(dbType, from),
+			QuoteIdentifier(dbType, other), strings.Join(conditions, " AND "))
+	}
+
+	return fmt.Sprintf("%s UNION ALL %s ORDER BY %s, %s",
+		side(tableA, tableB), side(tableB, tableA),
+		QuoteIdentifier(dbType, key), QuoteIdentifier(dbType, DiffSideColumn)), nil
+}
+
+// CurrentDatabase returns the name of the database a connection uses, e.g.
+// the one a connection string selects. SQLite and Athena don't report one.
+func CurrentDatabase(db *sql.DB, dbType DBType) (string, error) {
+	var query string
+	switch dbType {
+	case MySQL, MariaDB:
+		query = "SELECT DATABASE()"
+	case Postgres:
+		query = "SELECT current_database()"
+	default:
+		return "", fmt.Errorf("the database name isn't known for %s connections", dbType)
+	}
+
+	var name sql.NullString
+	if err := db.QueryRow(query).Scan(&name); err != nil {
+		return "", fmt.Errorf("error querying database name: %w", err)
+	}
+	if !name.Valid {
+		return "", fmt.Errorf("the connection has no database selected")
+	}
+	return name.String, nil
+}
+
 // GetTables returns a list of all tables in the database
 func GetTables(db *sql.DB, dbType DBType) ([]string, error) {
 	var query string
 
 	switch dbType {
-	case MySQL:
+	case MySQL, MariaDB:
+		query = `SELECT table_name FROM information_schema.tables
+				WHERE table_schema = DATABASE() AND table_type = 'BASE TABLE'`
+	case Athena:
+		// Athena lists views too, GetTablesWithCount drops them
 		query = "SHOW TABLES"
 	case Postgres:
 		query = `SELECT table_name FROM information_schema.tables 
-				WHERE table_schema = 'public'`
+				WHERE table_schema = current_schema() AND table_type = 'BASE TABLE'`
 	case SQLite:
 		query = `SELECT name FROM sqlite_master 
 				WHERE type='table' AND name NOT LIKE 'sqlite_%'`
@@ -123,97 +557,645 @@ func GetTables(db *sql.DB, dbType DBType) ([]string, error) {
 	return tables, nil
 }
 
+// GetViews returns the names of the views in the database, which export
+// like tables
+func GetViews(db *sql.DB, dbType DBType) ([]string, error) {
+	var query string
+
+	switch dbType {
+	case MySQL, MariaDB:
+		query = "SHOW FULL TABLES WHERE Table_type = 'VIEW'"
+	case Athena:
+		query = "SHOW VIEWS"
+	case Postgres:
+		query = `SELECT table_name FROM information_schema.views
+				WHERE table_schema = current_schema()`
+	case SQLite:
+		query = `SELECT name FROM sqlite_master WHERE type='view'`
+	default:
+		return nil, fmt.Errorf("unsupported database type: %s", dbType)
+	}
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying views: %w", err)
+	}
+	defer rows.Close()
+
+	var views []string
+	for rows.Next() {
+		var view string
+		var err error
+		if dbType == MySQL || dbType == MariaDB {
+			// The second column is the table type
+			var tableType string
+			err = rows.Scan(&view, &tableType)
+		} else {
+			err = rows.Scan(&view)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error scanning view name: %w", err)
+		}
+		views = append(views, view)
+	}
+
+	return views, rows.Err()
+}
+
+// ColumnInfo describes a column of a table
+type ColumnInfo struct {
+	Name     string
+	Type     string // declared type as the database reports it, e.g. varchar(255) or int4
+	Nullable bool
+}
+
 // GetColumns returns the column names for a given table
 func GetColumns(db *sql.DB, dbType DBType, tableName string) ([]string, error) {
+	infos, err := GetColumnsWithTypes(db, dbType, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]string, len(infos))
+	for i, info := range infos {
+		columns[i] = info.Name
+	}
+	return columns, nil
+}
+
+// GetColumnsWithTypes returns the columns of a table in table order with
+// their declared types and whether they allow NULL. Athena only reports
+// the names; its columns have no type and count as nullable.
+func GetColumnsWithTypes(db *sql.DB, dbType DBType, tableName string) ([]ColumnInfo, error) {
 	var query string
+	var args []interface{}
 
 	switch dbType {
-	case MySQL:
-		query = fmt.Sprintf("SHOW COLUMNS FROM %s", tableName)
+	case MySQL, MariaDB:
+		// Like the other databases, list the columns by ordinal position
+		// rather than rely on the order of SHOW COLUMNS
+		query = `
+			SELECT COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE
+			FROM information_schema.COLUMNS
+			WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?
+			ORDER BY ORDINAL_POSITION`
+		args = append(args, tableName)
 	case Postgres:
 		query = `
-			SELECT column_name 
-			FROM information_schema.columns 
-			WHERE table_name = $1 
+			SELECT column_name, udt_name, is_nullable
+			FROM information_schema.columns
+			WHERE table_name = $1 AND table_schema = current_schema()
 			ORDER BY ordinal_position`
+		args = append(args, tableName)
 	case SQLite:
-		query = fmt.Sprintf("PRAGMA table_info(%s)", tableName)
+		query = fmt.Sprintf("PRAGMA table_info(%s)", QuoteIdentifier(dbType, tableName))
+	case Athena:
+		// SHOW is a Hive DDL statement, which quotes identifiers with backticks
+		query = fmt.Sprintf("SHOW COLUMNS IN %s", QuoteIdentifier(MySQL, tableName))
 	default:
 		return nil, fmt.Errorf("unsupported database type: %s", dbType)
 	}
 
-	var columns []string
-	var rows *sql.Rows
-	var err error
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying columns: %w", err)
+	}
+	defer rows.Close()
 
-	if dbType == Postgres {
-		rows, err = db.Query(query, tableName)
-	} else {
-		rows, err = db.Query(query)
+	var columns []ColumnInfo
+	var keyColumns []int // SQLite primary key columns
+	for rows.Next() {
+		var name, typ, nullable sql.NullString
+		switch dbType {
+		case MySQL, MariaDB, Postgres:
+			err = rows.Scan(&name, &typ, &nullable)
+		case Athena:
+			err = rows.Scan(&name)
+			// Athena pads the names in SHOW COLUMNS output
+			name.String = strings.TrimSpace(name.String)
+			nullable.String = "YES"
+		case SQLite:
+			var cid, notnull, pk int
+			var dfltValue sql.NullString
+			err = rows.Scan(&cid, &name, &typ, &notnull, &dfltValue, &pk)
+			if notnull == 0 {
+				nullable.String = "YES"
+			}
+			if pk > 0 {
+				keyColumns = append(keyColumns, len(columns))
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error scanning column: %w", err)
+		}
+		columns = append(columns, ColumnInfo{
+			Name:     name.String,
+			Type:     typ.String,
+			Nullable: strings.EqualFold(nullable.String, "YES"),
+		})
+	}
+
+	// A lone INTEGER PRIMARY KEY is the rowid, which is never NULL
+	if len(keyColumns) == 1 && strings.EqualFold(columns[keyColumns[0]].Type, "INTEGER") {
+		columns[keyColumns[0]].Nullable = false
+	}
+
+	return columns, rows.Err()
+}
+
+// GetColumnTypes returns the lower-cased declared type of each column of a
+// table. Athena is not supported and returns no types.
+func GetColumnTypes(db *sql.DB, dbType DBType, tableName string) (map[string]string, error) {
+	if dbType == Athena {
+		return nil, nil
 	}
 
+	columns, err := GetColumnsWithTypes(db, dbType, tableName)
 	if err != nil {
-		return nil, fmt.Errorf("error querying columns: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
+
+	types := make(map[string]string, len(columns))
+	for _, column := range columns {
+		types[column.Name] = strings.ToLower(column.Type)
+	}
+	return types, nil
+}
+
+// GetPrimaryKey returns the primary key columns of a table in key order, or
+// nil if it has none. Athena tables have no primary keys.
+func GetPrimaryKey(db *sql.DB, dbType DBType, tableName string) ([]string, error) {
+	var query string
+	var args []interface{}
 
 	switch dbType {
-	case MySQL:
-		for rows.Next() {
-			var field, typ, null, key, default_, extra sql.NullString
-			if err := rows.Scan(&field, &typ, &null, &key, &default_, &extra); err != nil {
-				return nil, err
-			}
-			columns = append(columns, field.String)
+	case MySQL, MariaDB:
+		query = `
+			SELECT COLUMN_NAME
+			FROM information_schema.KEY_COLUMN_USAGE
+			WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND CONSTRAINT_NAME = 'PRIMARY'
+			ORDER BY ORDINAL_POSITION`
+		args = append(args, tableName)
+	case Postgres:
+		query = `
+			SELECT a.attname
+			FROM pg_index i
+			JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+			WHERE i.indrelid = $1::regclass AND i.indisprimary
+			ORDER BY array_position(i.indkey, a.attnum)`
+		args = append(args, QuoteIdentifier(dbType, tableName))
+	case SQLite:
+		query = fmt.Sprintf("SELECT name FROM pragma_table_info(%s) WHERE pk > 0 ORDER BY pk",
+			QuoteLiteral(dbType, tableName))
+	case Athena:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported database type: %s", dbType)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying primary key: %w", err)
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, fmt.Errorf("error scanning primary key column: %w", err)
 		}
+		columns = append(columns, column)
+	}
+
+	return columns, rows.Err()
+}
+
+// GetColumnComments returns the comment of each commented column of a
+// table. SQLite and Athena have no column comments and return nil.
+func GetColumnComments(db *sql.DB, dbType DBType, tableName string) (map[string]string, error) {
+	var query string
+	var args []interface{}
+
+	switch dbType {
+	case MySQL, MariaDB:
+		query = `
+			SELECT COLUMN_NAME, COLUMN_COMMENT
+			FROM information_schema.COLUMNS
+			WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?`
+		args = append(args, tableName)
 	case Postgres:
-		for rows.Next() {
-			var column string
-			if err := rows.Scan(&column); err != nil {
-				return nil, err
-			}
-			columns = append(columns, column)
+		query = `
+			SELECT a.attname, col_description(a.attrelid, a.attnum)
+			FROM pg_attribute a
+			WHERE a.attrelid = $1::regclass AND a.attnum > 0 AND NOT a.attisdropped`
+		args = append(args, QuoteIdentifier(dbType, tableName))
+	case SQLite, Athena:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported database type: %s", dbType)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying column comments: %w", err)
+	}
+	defer rows.Close()
+
+	comments := make(map[string]string)
+	for rows.Next() {
+		var name, comment sql.NullString
+		if err := rows.Scan(&name, &comment); err != nil {
+			return nil, fmt.Errorf("error scanning column comment: %w", err)
 		}
-	case SQLite:
-		for rows.Next() {
-			var cid int
-			var name, typ, notnull, dfltValue, pk sql.NullString
-			if err := rows.Scan(&cid, &name, &typ, &notnull, &dfltValue, &pk); err != nil {
-				return nil, err
-			}
-			columns = append(columns, name.String)
+		if comment.String != "" {
+			comments[name.String] = comment.String
 		}
 	}
 
-	return columns, nil
+	return comments, rows.Err()
+}
+
+// IsMySQLGeometryType reports whether a MySQL column type holds spatial
+// values, which the driver returns as WKB binary
+func IsMySQLGeometryType(typ string) bool {
+	// SHOW COLUMNS can append an SRID attribute, e.g. "point /*!80003 SRID 4326 */"
+	if i := strings.IndexAny(typ, " ("); i >= 0 {
+		typ = typ[:i]
+	}
+	switch typ {
+	case "geometry", "point", "linestring", "polygon", "multipoint",
+		"multilinestring", "multipolygon", "geometrycollection", "geomcollection":
+		return true
+	}
+	return false
+}
+
+// DiffColumns compares two column lists and returns the columns only present
+// in after (added) and only present in before (removed)
+func DiffColumns(before, after []string) (added, removed []string) {
+	beforeSet := make(map[string]bool, len(before))
+	for _, column := range before {
+		beforeSet[column] = true
+	}
+	afterSet := make(map[string]bool, len(after))
+	for _, column := range after {
+		afterSet[column] = true
+		if !beforeSet[column] {
+			added = append(added, column)
+		}
+	}
+	for _, column := range before {
+		if !afterSet[column] {
+			removed = append(removed, column)
+		}
+	}
+	return added, removed
+}
+
+// CommonColumns returns the columns present in every list, in the order of
+// the first
+func CommonColumns(columnLists [][]string) []string {
+	if len(columnLists) == 0 {
+		return nil
+	}
+
+	var common []string
+	for _, column := range columnLists[0] {
+		shared := true
+		for _, columns := range columnLists[1:] {
+			if !slices.Contains(columns, column) {
+				shared = false
+				break
+			}
+		}
+		if shared {
+			common = append(common, column)
+		}
+	}
+	return common
 }
 
 // TableInfo holds table name and its row count
 type TableInfo struct {
 	Name     string
 	RowCount int64
+	View     bool // a view rather than a base table
+
+	// Estimated is set when RowCount comes from the database's statistics,
+	// which can be off, rather than from counting
+	Estimated bool
 }
 
-// GetTablesWithCount returns a list of all tables in the database with their row counts
-func GetTablesWithCount(db *sql.DB, dbType DBType) ([]TableInfo, error) {
+// TableFilter narrows a list of tables by name. A table is kept when it
+// matches Pattern and Include (if set) and does not match Exclude (if set).
+type TableFilter struct {
+	Pattern string // glob such as user_*, see path.Match
+	Include *regexp.Regexp
+	Exclude *regexp.Regexp
+}
+
+// NewTableFilter compiles a glob and include and exclude regular expressions
+// into a filter; empty patterns are ignored
+func NewTableFilter(pattern, include, exclude string) (TableFilter, error) {
+	filter := TableFilter{Pattern: pattern}
+	var err error
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return filter, fmt.Errorf("invalid table pattern %q: %w", pattern, err)
+	}
+
+	if include != "" {
+		if filter.Include, err = regexp.Compile(include); err != nil {
+			return filter, fmt.Errorf("invalid include pattern %q: %w", include, err)
+		}
+	}
+	if exclude != "" {
+		if filter.Exclude, err = regexp.Compile(exclude); err != nil {
+			return filter, fmt.Errorf("invalid exclude pattern %q: %w", exclude, err)
+		}
+	}
+
+	return filter, nil
+}
+
+// Match reports whether a table name passes the filter
+func (f TableFilter) Match(name string) bool {
+	if f.Pattern != "" {
+		if ok, _ := path.Match(f.Pattern, name); !ok {
+			return false
+		}
+	}
+	if f.Include != nil && !f.Include.MatchString(name) {
+		return false
+	}
+	if f.Exclude != nil && f.Exclude.MatchString(name) {
+		return false
+	}
+	return true
+}
+
+// FilterTables returns the tables whose names pass the filter
+func FilterTables(tableInfos []TableInfo, filter TableFilter) []TableInfo {
+	var filtered []TableInfo
+	for _, info := range tableInfos {
+		if filter.Match(info.Name) {
+			filtered = append(filtered, info)
+		}
+	}
+	return filtered
+}
+
+// TableOrder is a sort order for table lists
+type TableOrder string
+
+const (
+	OrderByEngine   TableOrder = ""          // as listed by the database
+	OrderByName     TableOrder = "name"      // A to Z
+	OrderByNameDesc TableOrder = "name-desc" // Z to A
+	OrderByRows     TableOrder = "rows"      // largest first
+	OrderByRowsAsc  TableOrder = "rows-asc"  // smallest first
+)
+
+// ParseTableOrder validates a table sort order
+func ParseTableOrder(s string) (TableOrder, error) {
+	switch order := TableOrder(s); order {
+	case OrderByEngine, OrderByName, OrderByNameDesc, OrderByRows, OrderByRowsAsc:
+		return order, nil
+	default:
+		return "", fmt.Errorf("invalid table order %q, want name, name-desc, rows or rows-asc", s)
+	}
+}
+
+// SortTables sorts tables in place. Ties in row count are broken by name.
+func SortTables(tableInfos []TableInfo, order TableOrder) {
+	byName := func(i, j int) bool { return tableInfos[i].Name < tableInfos[j].Name }
+	switch order {
+	case OrderByName:
+		sort.SliceStable(tableInfos, byName)
+	case OrderByNameDesc:
+		sort.SliceStable(tableInfos, func(i, j int) bool { return byName(j, i) })
+	case OrderByRows:
+		sort.SliceStable(tableInfos, func(i, j int) bool {
+			if tableInfos[i].RowCount != tableInfos[j].RowCount {
+				return tableInfos[i].RowCount > tableInfos[j].RowCount
+			}
+			return byName(i, j)
+		})
+	case OrderByRowsAsc:
+		sort.SliceStable(tableInfos, func(i, j int) bool {
+			if tableInfos[i].RowCount != tableInfos[j].RowCount {
+				return tableInfos[i].RowCount < tableInfos[j].RowCount
+			}
+			return byName(i, j)
+		})
+	}
+}
+
+// GetTablesWithCount returns a list of all tables in the database with their
+// row counts, followed by the views. With estimate, tables whose row count
+// the database keeps in its statistics (MySQL and PostgreSQL)
+// get that count instead of being scanned; the others are still counted.
+func GetTablesWithCount(db *sql.DB, dbType DBType, estimate bool) ([]TableInfo, error) {
 	tables, err := GetTables(db, dbType)
 	if err != nil {
 		return nil, err
 	}
+	views, err := GetViews(db, dbType)
+	if err != nil {
+		return nil, err
+	}
+
+	var estimates map[string]int64
+	if estimate {
+		if estimates, err = estimateRowCounts(db, dbType); err != nil {
+			return nil, err
+		}
+	}
+
+	isView := make(map[string]bool, len(views))
+	for _, view := range views {
+		isView[view] = true
+	}
 
 	var tableInfos []TableInfo
 	for _, table := range tables {
-		var count int64
-		query := fmt.Sprintf("SELECT COUNT(*) FROM %s", table)
-		err := db.QueryRow(query).Scan(&count)
-		if err != nil {
-			return nil, fmt.Errorf("error counting rows in table %s: %w", table, err)
+		if !isView[table] {
+			tableInfos = append(tableInfos, TableInfo{Name: table})
+		}
+	}
+	for _, view := range views {
+		tableInfos = append(tableInfos, TableInfo{Name: view, View: true})
+	}
+
+	for i, info := range tableInfos {
+		if count, ok := estimates[info.Name]; ok && !info.View {
+			tableInfos[i].RowCount, tableInfos[i].Estimated = count, true
+			continue
+		}
+		if tableInfos[i].RowCount, err = CountRows(db, dbType, info.Name); err != nil {
+			return nil, err
 		}
-		tableInfos = append(tableInfos, TableInfo{
-			Name:     table,
-			RowCount: count,
-		})
 	}
 
 	return tableInfos, nil
 }
+
+// CountRows returns the number of rows in a table, scanning it
+func CountRows(db *sql.DB, dbType DBType, tableName string) (int64, error) {
+	var count int64
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s", QuoteIdentifier(dbType, tableName))
+	if err := db.QueryRow(query).Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting rows in table %s: %w", tableName, err)
+	}
+	return count, nil
+}
+
+// estimateRowCounts returns the row counts the database keeps for its
+// tables, without scanning them. Tables without statistics, and every table
+// of databases that don't keep any, are left out.
+func estimateRowCounts(db *sql.DB, dbType DBType) (map[string]int64, error) {
+	var query string
+
+	switch dbType {
+	case MySQL, MariaDB:
+		query = `SELECT table_name, table_rows FROM information_schema.tables
+				WHERE table_schema = DATABASE() AND table_type = 'BASE TABLE'`
+	case Postgres:
+		// reltuples is -1 for tables that were never vacuumed or analyzed
+		query = `SELECT c.relname, c.reltuples::bigint
+				FROM pg_class c
+				JOIN pg_namespace n ON n.oid = c.relnamespace
+				WHERE n.nspname = current_schema() AND c.relkind IN ('r', 'p') AND c.reltuples >= 0`
+	default:
+		return nil, nil
+	}
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying row count estimates: %w", err)
+	}
+	defer rows.Close()
+
+	estimates := make(map[string]int64)
+	for rows.Next() {
+		var name string
+		var count sql.NullInt64
+		if err := rows.Scan(&name, &count); err != nil {
+			return nil, fmt.Errorf("error scanning row count estimate: %w", err)
+		}
+		if count.Valid {
+			estimates[name] = count.Int64
+		}
+	}
+
+	return estimates, rows.Err()
+}
+
+// CheckReadOnly reports whether the connected user is unable to modify data,
+// along with a short explanation of how that was determined
+func CheckReadOnly(db *sql.DB, dbType DBType) (bool, string, error) {
+	switch dbType {
+	case MySQL, MariaDB:
+		return checkMySQLReadOnly(db)
+	case Postgres:
+		return checkPostgresReadOnly(db)
+	case SQLite:
+		return checkSQLiteReadOnly(db)
+	default:
+		return false, "", fmt.Errorf("unsupported database type: %s", dbType)
+	}
+}
+
+// checkMySQLReadOnly inspects the current user's grants for write privileges.
+// DDL is not transactional in MySQL, so a rolled-back write probe isn't safe.
+func checkMySQLReadOnly(db *sql.DB) (bool, string, error) {
+	rows, err := db.Query("SHOW GRANTS FOR CURRENT_USER()")
+	if err != nil {
+		return false, "", fmt.Errorf("error querying grants: %w", err)
+	}
+	defer rows.Close()
+
+	writePrivileges := []string{"ALL PRIVILEGES", "INSERT", "UPDATE", "DELETE", "CREATE", "DROP", "ALTER"}
+	for rows.Next() {
+		var grant string
+		if err := rows.Scan(&grant); err != nil {
+			return false, "", fmt.Errorf("error scanning grant: %w", err)
+		}
+
+		// Privileges are listed between GRANT and ON; role grants have no ON
+		upper := strings.ToUpper(grant)
+		end := strings.Index(upper, " ON ")
+		if !strings.HasPrefix(upper, "GRANT ") || end == -1 {
+			continue
+		}
+		for _, privilege := range strings.Split(upper[len("GRANT "):end], ",") {
+			privilege = strings.TrimSpace(privilege)
+			for _, write := range writePrivileges {
+				if privilege == write {
+					return false, fmt.Sprintf("user has %s privilege: %s", write, grant), nil
+				}
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, "", fmt.Errorf("error reading grants: %w", err)
+	}
+
+	return true, "no write privileges found in grants", nil
+}
+
+// checkPostgresReadOnly checks the session setting, role attributes and table
+// privileges of the current user
+func checkPostgresReadOnly(db *sql.DB) (bool, string, error) {
+	var readOnly string
+	if err := db.QueryRow("SELECT current_setting('transaction_read_only')").Scan(&readOnly); err != nil {
+		return false, "", fmt.Errorf("error checking transaction_read_only: %w", err)
+	}
+	if readOnly == "on" {
+		return true, "session is read-only (transaction_read_only = on)", nil
+	}
+
+	var superuser bool
+	if err := db.QueryRow("SELECT rolsuper FROM pg_roles WHERE rolname = current_user").Scan(&superuser); err != nil {
+		return false, "", fmt.Errorf("error checking role attributes: %w", err)
+	}
+	if superuser {
+		return false, "user is a superuser", nil
+	}
+
+	var table string
+	err := db.QueryRow(`
+		SELECT n.nspname || '.' || c.relname
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('r', 'p')
+			AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+			AND n.nspname NOT LIKE 'pg_toast%'
+			AND has_table_privilege(c.oid, 'INSERT, UPDATE, DELETE, TRUNCATE')
+		LIMIT 1`).Scan(&table)
+	if err == sql.ErrNoRows {
+		return true, "user has no INSERT, UPDATE, DELETE or TRUNCATE privileges", nil
+	}
+	if err != nil {
+		return false, "", fmt.Errorf("error checking table privileges: %w", err)
+	}
+
+	return false, fmt.Sprintf("user can modify table %s", table), nil
+}
+
+// checkSQLiteReadOnly attempts a write inside a transaction that is always
+// rolled back
+func checkSQLiteReadOnly(db *sql.DB) (bool, string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, "", fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("CREATE TABLE sql2csv_readonly_probe (id INTEGER)"); err != nil {
+		return true, fmt.Sprintf("write probe failed: %v", err), nil
+	}
+
+	return false, "write probe succeeded", nil
+}
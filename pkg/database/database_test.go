@@ -2,8 +2,13 @@ package database
 
 import (
 	"database/sql"
+	"fmt"
+	"net"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestConnect(t *testing.T) {
@@ -34,6 +39,24 @@ func TestConnect(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "Unsupported Postgres SSL Mode",
+			config: Config{
+				Type:    Postgres,
+				Host:    "localhost",
+				Port:    5432,
+				SSLMode: "prefer",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Athena Without Connection String",
+			config: Config{
+				Type: Athena,
+				Host: "localhost",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -50,6 +73,148 @@ func TestConnect(t *testing.T) {
 	}
 }
 
+func TestConnect_PoolLimits(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	tests := []struct {
+		name     string
+		maxOpen  int
+		wantOpen int
+	}{
+		{name: "Default", wantOpen: DefaultMaxOpenConns},
+		{name: "Limited", maxOpen: 3, wantOpen: 3},
+		{name: "Unlimited", maxOpen: -1, wantOpen: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := Connect(Config{Type: SQLite, FilePath: tmpfile.Name(), MaxOpenConns: tt.maxOpen})
+			if err != nil {
+				t.Fatalf("Connect() error = %v", err)
+			}
+			defer db.Close()
+			if got := db.Stats().MaxOpenConnections; got != tt.wantOpen {
+				t.Errorf("MaxOpenConnections = %d, want %d", got, tt.wantOpen)
+			}
+		})
+	}
+}
+
+func TestCheckType(t *testing.T) {
+	for _, dbType := range Types() {
+		if err := CheckType(dbType); err != nil {
+			t.Errorf("CheckType(%s) error = %v", dbType, err)
+		}
+	}
+	if err := CheckType("oracle"); err == nil {
+		t.Error("CheckType(oracle) returned no error")
+	}
+
+	// SQL Server is only a dialect of the sql format
+	if err := CheckType(SQLServer); err == nil {
+		t.Error("CheckType(sqlserver) returned no error")
+	}
+}
+
+func TestPostgresDSN(t *testing.T) {
+	base := Config{Type: Postgres, Host: "db", Port: 5432, User: "u", Password: "p", DBName: "shop"}
+	tests := []struct {
+		name    string
+		sslMode string
+		schema  string
+		socket  string
+		want    string
+		wantErr bool
+		edit    func(*Config) // changes to the base configuration
+	}{
+		{
+			name: "Defaults",
+			want: `host=db port=5432 user='u' password='p' dbname='shop' sslmode=disable search_path='"public"'`,
+		},
+		{
+			name:    "SSL mode and schema",
+			sslMode: "verify-full",
+			schema:  "Sales",
+			want:    `host=db port=5432 user='u' password='p' dbname='shop' sslmode=verify-full search_path='"Sales"'`,
+		},
+		{
+			name:   "Schema with quotes",
+			schema: `it's "odd"`,
+			want:   `host=db port=5432 user='u' password='p' dbname='shop' sslmode=disable search_path='"it\'s ""odd"""'`,
+		},
+		{
+			name:   "Socket directory",
+			socket: "/var/run/postgresql",
+			want:   `host='/var/run/postgresql' port=5432 user='u' password='p' dbname='shop' sslmode=disable search_path='"public"'`,
+		},
+		{
+			name:   "Socket file",
+			socket: "/tmp/.s.PGSQL.5433",
+			want:   `host='/tmp' port=5433 user='u' password='p' dbname='shop' sslmode=disable search_path='"public"'`,
+		},
+		{
+			name: "Credentials with spaces and quotes",
+			edit: func(c *Config) { c.User, c.Password, c.DBName = "app user", `p'w d\`, "my shop" },
+			want: `host=db port=5432 user='app user' password='p\'w d\\' dbname='my shop' sslmode=disable search_path='"public"'`,
+		},
+		{
+			name: "Empty password",
+			edit: func(c *Config) { c.Password = "" },
+			want: `host=db port=5432 user='u' password='' dbname='shop' sslmode=disable search_path='"public"'`,
+		},
+		{name: "Unsupported SSL mode", sslMode: "prefer", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := base
+			config.SSLMode, config.Schema, config.Socket = tt.sslMode, tt.schema, tt.socket
+			if tt.edit != nil {
+				tt.edit(&config)
+			}
+			got, err := postgresDSN(config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("postgresDSN() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("postgresDSN() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMySQLDSN(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   string
+	}{
+		{
+			name:   "TCP",
+			config: Config{Type: MySQL, Host: "db", Port: 3306, User: "u", Password: "p", DBName: "shop"},
+			want:   "u:p@tcp(db:3306)/shop",
+		},
+		{
+			name:   "Unix socket",
+			config: Config{Type: MariaDB, Host: "db", Port: 3306, User: "u", Password: "p", DBName: "shop", Socket: "/run/mysqld/mysqld.sock"},
+			want:   "u:p@unix(/run/mysqld/mysqld.sock)/shop",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mysqlDSN(tt.config); got != tt.want {
+				t.Errorf("mysqlDSN() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestGetTables(t *testing.T) {
 	// Create a temporary SQLite database for testing
 	tmpfile, err := os.CreateTemp("", "test.db")
@@ -86,6 +251,56 @@ func TestGetTables(t *testing.T) {
 	}
 }
 
+func TestGetViews(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE users (id INTEGER PRIMARY KEY, active INTEGER);
+		INSERT INTO users (active) VALUES (1), (0), (1);
+		CREATE VIEW active_users AS SELECT id FROM users WHERE active = 1;
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test schema: %v", err)
+	}
+
+	views, err := GetViews(db, SQLite)
+	if err != nil {
+		t.Fatalf("GetViews() error = %v", err)
+	}
+	if len(views) != 1 || views[0] != "active_users" {
+		t.Errorf("GetViews() = %v, want [active_users]", views)
+	}
+
+	infos, err := GetTablesWithCount(db, SQLite, false)
+	if err != nil {
+		t.Fatalf("GetTablesWithCount() error = %v", err)
+	}
+	want := []TableInfo{{Name: "users", RowCount: 3}, {Name: "active_users", RowCount: 2, View: true}}
+	if len(infos) != len(want) || infos[0] != want[0] || infos[1] != want[1] {
+		t.Errorf("GetTablesWithCount() = %v, want %v", infos, want)
+	}
+
+	// SQLite keeps no row counts, so estimates fall back to counting
+	infos, err = GetTablesWithCount(db, SQLite, true)
+	if err != nil {
+		t.Fatalf("GetTablesWithCount() error = %v", err)
+	}
+	if len(infos) != len(want) || infos[0] != want[0] || infos[1] != want[1] {
+		t.Errorf("GetTablesWithCount() with estimates = %v, want %v", infos, want)
+	}
+}
+
 func TestGetColumns(t *testing.T) {
 	// Create a temporary SQLite database for testing
 	tmpfile, err := os.CreateTemp("", "test.db")
@@ -130,3 +345,749 @@ func TestGetColumns(t *testing.T) {
 		}
 	}
 }
+
+// postgresTestEnv names the environment variable with the connection string
+// of a PostgreSQL server for the tests that need one; they are skipped
+// without it
+const postgresTestEnv = "SQL2CSV_TEST_POSTGRES"
+
+func TestGetColumns_PostgresSchemas(t *testing.T) {
+	dsn := os.Getenv(postgresTestEnv)
+	if dsn == "" {
+		t.Skipf("$%s is not set", postgresTestEnv)
+	}
+	db, err := Connect(Config{Type: Postgres, ConnectionURL: dsn})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer db.Close()
+	// SET search_path only applies to its own connection
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		CREATE TABLE public.sql2csv_schema_test (id INTEGER, name TEXT);
+		CREATE SCHEMA sql2csv_other;
+		CREATE TABLE sql2csv_other.sql2csv_schema_test (id INTEGER, other TEXT, extra TEXT);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test tables: %v", err)
+	}
+	defer db.Exec(`
+		DROP TABLE public.sql2csv_schema_test;
+		DROP SCHEMA sql2csv_other CASCADE;
+		RESET search_path;
+	`)
+
+	tests := []struct {
+		schema string
+		want   []string
+	}{
+		{schema: "public", want: []string{"id", "name"}},
+		{schema: "sql2csv_other", want: []string{"id", "other", "extra"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.schema, func(t *testing.T) {
+			if _, err := db.Exec("SET search_path TO " + QuoteIdentifier(Postgres, tt.schema)); err != nil {
+				t.Fatalf("Failed to set search_path: %v", err)
+			}
+			columns, err := GetColumns(db, Postgres, "sql2csv_schema_test")
+			if err != nil {
+				t.Fatalf("GetColumns() error = %v", err)
+			}
+			if !reflect.DeepEqual(columns, tt.want) {
+				t.Errorf("GetColumns() = %v, want %v", columns, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	tests := []struct {
+		name   string
+		dbType DBType
+		ident  string
+		want   string
+	}{
+		{name: "MySQL", dbType: MySQL, ident: "order", want: "`order`"},
+		{name: "MySQL embedded backtick", dbType: MySQL, ident: "we`ird", want: "`we``ird`"},
+		{name: "MariaDB", dbType: MariaDB, ident: "order", want: "`order`"},
+		{name: "Postgres", dbType: Postgres, ident: "order", want: `"order"`},
+		{name: "SQLite embedded quote", dbType: SQLite, ident: `we"ird`, want: `"we""ird"`},
+		{name: "SQL Server", dbType: SQLServer, ident: "group by", want: "[group by]"},
+		{name: "SQL Server embedded bracket", dbType: SQLServer, ident: "we]ird", want: "[we]]ird]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := QuoteIdentifier(tt.dbType, tt.ident); got != tt.want {
+				t.Errorf("QuoteIdentifier() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteLiteral(t *testing.T) {
+	tests := []struct {
+		name   string
+		dbType DBType
+		s      string
+		want   string
+	}{
+		{name: "Postgres embedded quote", dbType: Postgres, s: "O'Brien", want: "'O''Brien'"},
+		{name: "MySQL backslash", dbType: MySQL, s: `C:\temp`, want: `'C:\\temp'`},
+		{name: "SQL Server unicode", dbType: SQLServer, s: "Zoë's", want: "N'Zoë''s'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := QuoteLiteral(tt.dbType, tt.s); got != tt.want {
+				t.Errorf("QuoteLiteral() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReservedWordTable(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE "order" (
+			id INTEGER PRIMARY KEY,
+			total REAL
+		);
+		INSERT INTO "order" (total) VALUES (9.99), (19.99);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	columns, err := GetColumns(db, SQLite, "order")
+	if err != nil {
+		t.Fatalf("GetColumns() error = %v", err)
+	}
+	if len(columns) != 2 || columns[0] != "id" || columns[1] != "total" {
+		t.Errorf("GetColumns() = %v, want [id total]", columns)
+	}
+
+	infos, err := GetTablesWithCount(db, SQLite, false)
+	if err != nil {
+		t.Fatalf("GetTablesWithCount() error = %v", err)
+	}
+	if len(infos) != 1 || infos[0].Name != "order" || infos[0].RowCount != 2 {
+		t.Errorf("GetTablesWithCount() = %v, want [{order 2}]", infos)
+	}
+}
+
+func TestCheckReadOnly(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`CREATE TABLE test_table (id INTEGER PRIMARY KEY)`); err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	readOnly, _, err := CheckReadOnly(db, SQLite)
+	if err != nil {
+		t.Fatalf("CheckReadOnly() error = %v", err)
+	}
+	if readOnly {
+		t.Error("CheckReadOnly() = true for a writable database, want false")
+	}
+
+	// The probe must not leave anything behind
+	tables, err := GetTables(db, SQLite)
+	if err != nil {
+		t.Fatalf("GetTables() error = %v", err)
+	}
+	if len(tables) != 1 {
+		t.Errorf("GetTables() = %v, want only test_table", tables)
+	}
+
+	roDB, err := sql.Open("sqlite3", "file:"+tmpfile.Name()+"?mode=ro")
+	if err != nil {
+		t.Fatalf("Failed to open read-only database: %v", err)
+	}
+	defer roDB.Close()
+
+	readOnly, _, err = CheckReadOnly(roDB, SQLite)
+	if err != nil {
+		t.Fatalf("CheckReadOnly() error = %v", err)
+	}
+	if !readOnly {
+		t.Error("CheckReadOnly() = false for a read-only database, want true")
+	}
+}
+
+func TestFilterTables(t *testing.T) {
+	tables := []TableInfo{
+		{Name: "users"},
+		{Name: "user_roles"},
+		{Name: "user_roles_backup"},
+		{Name: "orders"},
+	}
+
+	tests := []struct {
+		name    string
+		pattern string
+		include string
+		exclude string
+		want    []string
+		wantErr bool
+	}{
+		{name: "No patterns", want: []string{"users", "user_roles", "user_roles_backup", "orders"}},
+		{name: "Include only", include: `^user`, want: []string{"users", "user_roles", "user_roles_backup"}},
+		{name: "Exclude only", exclude: `_backup$`, want: []string{"users", "user_roles", "orders"}},
+		{name: "Include and exclude", include: `^user_`, exclude: `_backup$`, want: []string{"user_roles"}},
+		{name: "Invalid pattern", include: `user(`, wantErr: true},
+		{name: "Glob", pattern: "user_*", want: []string{"user_roles", "user_roles_backup"}},
+		{name: "Glob and exclude", pattern: "user*", exclude: `_backup$`, want: []string{"users", "user_roles"}},
+		{name: "Invalid glob", pattern: "user_[", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := NewTableFilter(tt.pattern, tt.include, tt.exclude)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewTableFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			got := FilterTables(tables, filter)
+			if len(got) != len(tt.want) {
+				t.Fatalf("FilterTables() = %v, want %v", got, tt.want)
+			}
+			for i, info := range got {
+				if info.Name != tt.want[i] {
+					t.Errorf("FilterTables()[%d] = %s, want %s", i, info.Name, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCommonColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns [][]string
+		want    []string
+	}{
+		{name: "No tables"},
+		{name: "One table", columns: [][]string{{"id", "name"}}, want: []string{"id", "name"}},
+		{
+			name:    "Order of the first table",
+			columns: [][]string{{"id", "email", "name"}, {"name", "id", "created_at"}, {"name", "email", "id"}},
+			want:    []string{"id", "name"},
+		},
+		{name: "Nothing shared", columns: [][]string{{"id"}, {"uuid"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CommonColumns(tt.columns)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("CommonColumns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffColumns(t *testing.T) {
+	tests := []struct {
+		name        string
+		before      []string
+		after       []string
+		wantAdded   []string
+		wantRemoved []string
+	}{
+		{name: "Unchanged", before: []string{"id", "name"}, after: []string{"id", "name"}},
+		{name: "Reordered", before: []string{"id", "name"}, after: []string{"name", "id"}},
+		{name: "Added", before: []string{"id"}, after: []string{"id", "email"}, wantAdded: []string{"email"}},
+		{name: "Removed", before: []string{"id", "email"}, after: []string{"id"}, wantRemoved: []string{"email"}},
+		{
+			name:        "Renamed",
+			before:      []string{"id", "mail"},
+			after:       []string{"id", "email"},
+			wantAdded:   []string{"email"},
+			wantRemoved: []string{"mail"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, removed := DiffColumns(tt.before, tt.after)
+			if strings.Join(added, ",") != strings.Join(tt.wantAdded, ",") {
+				t.Errorf("DiffColumns() added = %v, want %v", added, tt.wantAdded)
+			}
+			if strings.Join(removed, ",") != strings.Join(tt.wantRemoved, ",") {
+				t.Errorf("DiffColumns() removed = %v, want %v", removed, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+func TestGetColumnTypes(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE test_table (id INTEGER PRIMARY KEY, doc JSON, location POINT)`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	types, err := GetColumnTypes(db, SQLite, "test_table")
+	if err != nil {
+		t.Fatalf("GetColumnTypes() error = %v", err)
+	}
+
+	expected := map[string]string{"id": "integer", "doc": "json", "location": "point"}
+	for column, want := range expected {
+		if types[column] != want {
+			t.Errorf("GetColumnTypes()[%s] = %q, want %q", column, types[column], want)
+		}
+	}
+}
+
+func TestGetColumnsWithTypes(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE test_table (id INTEGER PRIMARY KEY, email VARCHAR(255) NOT NULL, created DATETIME)`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	columns, err := GetColumnsWithTypes(db, SQLite, "test_table")
+	if err != nil {
+		t.Fatalf("GetColumnsWithTypes() error = %v", err)
+	}
+
+	expected := []ColumnInfo{
+		{Name: "id", Type: "INTEGER"},
+		{Name: "email", Type: "VARCHAR(255)"},
+		{Name: "created", Type: "DATETIME", Nullable: true},
+	}
+	if !reflect.DeepEqual(columns, expected) {
+		t.Errorf("GetColumnsWithTypes() = %+v, want %+v", columns, expected)
+	}
+}
+
+func TestIsMySQLGeometryType(t *testing.T) {
+	tests := []struct {
+		typ  string
+		want bool
+	}{
+		{"point", true},
+		{"geometry", true},
+		{"multipolygon", true},
+		{"point /*!80003 SRID 4326 */", true},
+		{"json", false},
+		{"varchar(255)", false},
+		{"blob", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.typ, func(t *testing.T) {
+			if got := IsMySQLGeometryType(tt.typ); got != tt.want {
+				t.Errorf("IsMySQLGeometryType(%q) = %v, want %v", tt.typ, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCastToText(t *testing.T) {
+	tests := []struct {
+		name    string
+		dbType  DBType
+		column  string
+		want    string
+		wantErr bool
+	}{
+		{name: "MySQL", dbType: MySQL, column: "price", want: "CAST(`price` AS CHAR) AS `price`"},
+		{name: "Postgres", dbType: Postgres, column: "price", want: `CAST("price" AS TEXT) AS "price"`},
+		{name: "SQLite", dbType: SQLite, column: "price", want: `CAST("price" AS TEXT) AS "price"`},
+		{name: "Athena", dbType: Athena, column: "price", want: `CAST("price" AS VARCHAR) AS "price"`},
+		{name: "Unsupported", dbType: "oracle", column: "price", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CastToText(tt.dbType, tt.column)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CastToText() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("CastToText() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRandomSample(t *testing.T) {
+	tests := []struct {
+		name    string
+		dbType  DBType
+		want    string
+		wantErr bool
+	}{
+		{name: "MySQL", dbType: MySQL, want: "RAND() < 0.025"},
+		{name: "Postgres", dbType: Postgres, want: "random() < 0.025"},
+		{name: "SQLite", dbType: SQLite, want: "RANDOM() / 18446744073709551616.0 + 0.5 < 0.025"},
+		{name: "Unsupported", dbType: "oracle", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RandomSample(tt.dbType, 2.5)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RandomSample() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("RandomSample() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColumnsFromQuery(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE catalog (table_name TEXT, column_name TEXT, pii INTEGER);
+		INSERT INTO catalog VALUES
+			('users', 'id', 0), ('users', 'email', 1), ('users', 'name', 0),
+			('o''brien', 'id', 0);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create catalog table: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		table   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "Filtered by table",
+			query: "SELECT column_name FROM catalog WHERE table_name = {table} AND pii = 0 ORDER BY column_name",
+			table: "users",
+			want:  []string{"id", "name"},
+		},
+		{
+			name:  "Extra result columns and quoted table name",
+			query: "SELECT column_name, pii FROM catalog WHERE table_name = {table}",
+			table: "o'brien",
+			want:  []string{"id"},
+		},
+		{
+			name:    "No rows",
+			query:   "SELECT column_name FROM catalog WHERE table_name = {table}",
+			table:   "orders",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid query",
+			query:   "SELECT nope FROM missing",
+			table:   "users",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ColumnsFromQuery(db, SQLite, tt.query, tt.table)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ColumnsFromQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("ColumnsFromQuery() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffQuery(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE old (id INTEGER, name TEXT);
+		CREATE TABLE new (id INTEGER, name TEXT);
+		INSERT INTO old VALUES (1, 'a'), (2, 'b'), (3, NULL), (4, 'd');
+		INSERT INTO new VALUES (1, 'a'), (2, 'B'), (3, NULL), (5, 'e');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test tables: %v", err)
+	}
+
+	query, err := DiffQuery(SQLite, "old", "new", []string{"id", "name"}, "id")
+	if err != nil {
+		t.Fatalf("DiffQuery() error = %v", err)
+	}
+
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatalf("Failed to run diff query: %v", err)
+	}
+	defer rows.Close()
+
+	var got []string
+	for rows.Next() {
+		var side, name string
+		var id int
+		if err := rows.Scan(&side, &id, &name); err != nil {
+			t.Fatalf("Failed to scan row: %v", err)
+		}
+		got = append(got, fmt.Sprintf("%s:%d:%s", side, id, name))
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("Failed to read rows: %v", err)
+	}
+
+	// NULLs compare equal, and a changed row appears on both sides
+	want := []string{"new:2:B", "old:2:b", "old:4:d", "new:5:e"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("diff rows = %v, want %v", got, want)
+	}
+
+	if _, err := DiffQuery(SQLite, "old", "new", []string{"id", "name"}, "missing"); err == nil {
+		t.Error("DiffQuery() with unknown key column should fail")
+	}
+}
+
+func TestGetPrimaryKey(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);
+		CREATE TABLE "order" (region TEXT, num INTEGER, total REAL, PRIMARY KEY (num, region));
+		CREATE TABLE log (line TEXT);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test tables: %v", err)
+	}
+
+	tests := []struct {
+		table string
+		want  []string
+	}{
+		{table: "users", want: []string{"id"}},
+		{table: "order", want: []string{"num", "region"}},
+		{table: "log", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.table, func(t *testing.T) {
+			got, err := GetPrimaryKey(db, SQLite, tt.table)
+			if err != nil {
+				t.Fatalf("GetPrimaryKey() error = %v", err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("GetPrimaryKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountRows(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);
+		INSERT INTO users (name) VALUES ('a'), ('b'), ('c');
+		CREATE TABLE "order" (id INTEGER);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test tables: %v", err)
+	}
+
+	tests := []struct {
+		table   string
+		want    int64
+		wantErr bool
+	}{
+		{table: "users", want: 3},
+		{table: "order", want: 0},
+		{table: "missing", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.table, func(t *testing.T) {
+			got, err := CountRows(db, SQLite, tt.table)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CountRows() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("CountRows() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortTables(t *testing.T) {
+	tables := []TableInfo{{Name: "orders", RowCount: 50}, {Name: "users", RowCount: 10}, {Name: "audit", RowCount: 50}, {Name: "items", RowCount: 200}}
+
+	tests := []struct {
+		order string
+		want  []string
+	}{
+		{order: "", want: []string{"orders", "users", "audit", "items"}},
+		{order: "name", want: []string{"audit", "items", "orders", "users"}},
+		{order: "name-desc", want: []string{"users", "orders", "items", "audit"}},
+		{order: "rows", want: []string{"items", "audit", "orders", "users"}},
+		{order: "rows-asc", want: []string{"users", "audit", "orders", "items"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.order, func(t *testing.T) {
+			order, err := ParseTableOrder(tt.order)
+			if err != nil {
+				t.Fatalf("ParseTableOrder() error = %v", err)
+			}
+			sorted := append([]TableInfo(nil), tables...)
+			SortTables(sorted, order)
+			var got []string
+			for _, info := range sorted {
+				got = append(got, info.Name)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("SortTables() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseTableOrder("size"); err == nil {
+		t.Error("ParseTableOrder() with an unknown order should fail")
+	}
+}
+
+func TestConnect_Timeout(t *testing.T) {
+	// A server that accepts connections but never answers
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	start := time.Now()
+	_, err = Connect(Config{
+		Type:           Postgres,
+		Host:           "127.0.0.1",
+		Port:           port,
+		ConnectTimeout: 100 * time.Millisecond,
+	})
+	if err == nil {
+		t.Fatal("Connect() succeeded, want a timeout")
+	}
+	if want := fmt.Sprintf("at 127.0.0.1:%d: no response within 100ms", port); !strings.Contains(err.Error(), want) {
+		t.Errorf("Connect() error = %v, want it to contain %q", err, want)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Connect() took %s, want it to give up after the timeout", elapsed)
+	}
+}
+
+func TestDBType_driver(t *testing.T) {
+	for dbType, want := range map[DBType]string{MySQL: "mysql", MariaDB: "mysql", Postgres: "postgres", SQLite: "sqlite3"} {
+		if got := dbType.driver(); got != want {
+			t.Errorf("%s.driver() = %s, want %s", dbType, got, want)
+		}
+	}
+}
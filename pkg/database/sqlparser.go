@@ -2,27 +2,118 @@ package database
 
 import (
 	"bufio"
+	"compress/gzip"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"regexp"
 	"strings"
+	"sync/atomic"
 )
 
 // SQLDumpParser handles parsing of SQL dump files
 type SQLDumpParser struct {
 	filePath string
+	reader   io.Reader // read instead of filePath when set
 	dbType   DBType
 	debug    bool
+
+	// retryFailed runs the statements that failed a second time after the
+	// whole dump was read
+	retryFailed bool
+	failed      []string
+
+	// exactNumeric imports DECIMAL/NUMERIC columns as TEXT instead of
+	// letting SQLite store them as floating point
+	exactNumeric   bool
+	numericColumns []string
+
+	// emptyAsNull imports empty COPY fields as NULL instead of ''
+	emptyAsNull bool
+
+	// keepConstraints adds the keys and checks of ALTER TABLE ... ADD
+	// CONSTRAINT statements to their tables once the dump is read
+	keepConstraints bool
+	constraints     []tableConstraints
+
+	summary ImportSummary
+
+	// maxLineSize is the longest line of the dump that can be read
+	maxLineSize int
+
+	onTempFile func(path string)
 }
 
-// NewSQLDumpParser creates a new SQL dump parser
+// DefaultMaxDumpLineSize is the longest dump line read unless
+// SetMaxLineSize changes it. Dumps put each multi-row INSERT or COPY row on
+// one line, so lines can be far longer than bufio.Scanner's 64KB default.
+const DefaultMaxDumpLineSize = 10 << 20
+
+// numericColumnPattern matches a column definition of an exact numeric type
+// inside a CREATE TABLE statement
+var numericColumnPattern = regexp.MustCompile(`^("[^"]+"|` + "`[^`]+`" +
+	`|[\w$]+)\s+((?i:numeric|decimal)\b(\s*\(\s*\d+\s*(,\s*\d+\s*)?\))?)`)
+
+// insertTablePattern captures the table name of an INSERT statement
+var insertTablePattern = regexp.MustCompile(`(?i)^INSERT\s+(?:IGNORE\s+)?INTO\s+([^\s(]+)`)
+
+// ImportSummary counts what happened to the statements of an imported dump.
+// A COPY block counts as one statement.
+type ImportSummary struct {
+	Executed int              // statements that ran
+	Skipped  int              // statements SQLite has no use for, left out
+	Failed   int              // statements that failed, after any retry
+	Rows     map[string]int64 // rows inserted by table
+}
+
+// createTablePattern captures the table name of a CREATE TABLE statement
+var createTablePattern = regexp.MustCompile(`(?i)^CREATE TABLE\s+(?:IF NOT EXISTS\s+)?([^\s(]+)`)
+
+// mysqlIndexPattern matches the index definitions of a MySQL CREATE TABLE,
+// which SQLite only knows as CREATE INDEX statements
+var mysqlIndexPattern = regexp.MustCompile(`(?i)^(?:KEY|INDEX|FULLTEXT|SPATIAL)\b`)
+
+// mysqlUniqueKeyPattern matches the start of a named MySQL unique key
+var mysqlUniqueKeyPattern = regexp.MustCompile(`(?i)^UNIQUE\s+(?:KEY|INDEX)\s*(?:"(?:[^"]|"")*"|\w+)?\s*\(`)
+
+// mysqlEnumPattern matches the start of a MySQL ENUM or SET column
+// definition, capturing the column name and the space after it
+var mysqlEnumPattern = regexp.MustCompile(`(?i)^((?:"(?:[^"]|"")*"|[\w$]+)\s+)(?:ENUM|SET)\s*\(`)
+
+// mysqlCommentPattern matches the COMMENT of a MySQL column definition
+var mysqlCommentPattern = regexp.MustCompile(`(?i)\s+COMMENT\s+'(?:[^'\\]|\\.|'')*'`)
+
+// mysqlAttributePattern matches the MySQL column and key attributes SQLite
+// doesn't accept
+var mysqlAttributePattern = regexp.MustCompile(`(?i)\s+(?:AUTO_INCREMENT\b|CHARACTER SET\s+\w+|COLLATE\s+\w+|` +
+	`ON UPDATE CURRENT_TIMESTAMP(?:\(\d*\))?|USING\s+(?:BTREE|HASH)\b)`)
+
+// StdinDump is the dump file path that reads the dump from stdin
+const StdinDump = "-"
+
+// NewSQLDumpParser creates a new SQL dump parser. A filePath of StdinDump
+// reads the dump from stdin.
 func NewSQLDumpParser(filePath string, dbType DBType) *SQLDumpParser {
-	return &SQLDumpParser{
+	p := &SQLDumpParser{
 		filePath: filePath,
 		dbType:   dbType,
 		debug:    false,
 	}
+	if filePath == StdinDump {
+		p.reader = os.Stdin
+	}
+	return p
+}
+
+// NewSQLDumpParserFromReader creates a SQL dump parser that reads the dump,
+// gzipped or not, from r, e.g. a pipe. The dump can only be imported once.
+func NewSQLDumpParserFromReader(r io.Reader, dbType DBType) *SQLDumpParser {
+	return &SQLDumpParser{
+		reader: r,
+		dbType: dbType,
+	}
 }
 
 // SetDebug enables or disables debug logging
@@ -30,14 +121,73 @@ func (p *SQLDumpParser) SetDebug(debug bool) {
 	p.debug = debug
 }
 
-// logDebug prints a message if debug mode is enabled
+// SetRetryFailed enables a second pass over the statements that failed,
+// for dumps that reference tables before creating them
+func (p *SQLDumpParser) SetRetryFailed(retry bool) {
+	p.retryFailed = retry
+}
+
+// SetExactNumeric imports DECIMAL and NUMERIC columns as TEXT so their
+// values are not rounded to floating point
+func (p *SQLDumpParser) SetExactNumeric(exact bool) {
+	p.exactNumeric = exact
+}
+
+// SetEmptyAsNull imports empty fields of COPY data as NULL instead of the
+// empty string. COPY writes NULL as \N, so only enable this for dumps whose
+// empty fields are meant to be NULL.
+func (p *SQLDumpParser) SetEmptyAsNull(emptyAsNull bool) {
+	p.emptyAsNull = emptyAsNull
+}
+
+// NumericColumns returns the DECIMAL and NUMERIC columns found in the last
+// import as "table.column". Unless exact numeric mode is on, SQLite stores
+// their values as floating point, which can lose precision.
+func (p *SQLDumpParser) NumericColumns() []string {
+	return p.numericColumns
+}
+
+// SetKeepConstraints adds the PRIMARY KEY, UNIQUE, FOREIGN KEY and CHECK
+// constraints that PostgreSQL dumps add with ALTER TABLE to the imported
+// tables. SQLite can't add constraints to existing tables, so each table is
+// rebuilt once the whole dump is read. Without it those statements fail.
+func (p *SQLDumpParser) SetKeepConstraints(keep bool) {
+	p.keepConstraints = keep
+}
+
+// SetMaxLineSize sets the longest line of the dump that can be read, in
+// bytes; 0 restores DefaultMaxDumpLineSize
+func (p *SQLDumpParser) SetMaxLineSize(size int) {
+	p.maxLineSize = size
+}
+
+// SetOnTempFile registers a function that is called with the path of the
+// temporary database as soon as it is created, so callers can remove it if
+// the import is aborted
+func (p *SQLDumpParser) SetOnTempFile(fn func(path string)) {
+	p.onTempFile = fn
+}
+
+// Summary returns the statement and row counts of the last import
+func (p *SQLDumpParser) Summary() ImportSummary {
+	return p.summary
+}
+
+// FailedStatements returns the statements that failed in the last import,
+// after the retry pass if it was enabled
+func (p *SQLDumpParser) FailedStatements() []string {
+	return p.failed
+}
+
+// logDebug prints a message to stderr if debug mode is enabled
 func (p *SQLDumpParser) logDebug(format string, args ...interface{}) {
 	if p.debug {
-		fmt.Printf(format, args...)
+		fmt.Fprintf(os.Stderr, format, args...)
 	}
 }
 
-// ParseToSQLite converts a SQL dump file to a SQLite database
+// ParseToSQLite converts a SQL dump file to a SQLite database in a
+// temporary file and returns its path
 func (p *SQLDumpParser) ParseToSQLite() (string, error) {
 	// Create a temporary SQLite database
 	tmpfile, err := os.CreateTemp("", "sql_import_*.db")
@@ -45,6 +195,9 @@ func (p *SQLDumpParser) ParseToSQLite() (string, error) {
 		return "", fmt.Errorf("failed to create temp database: %w", err)
 	}
 	tmpfile.Close()
+	if p.onTempFile != nil {
+		p.onTempFile(tmpfile.Name())
+	}
 
 	// Connect to the temporary database
 	db, err := Connect(Config{
@@ -57,25 +210,109 @@ func (p *SQLDumpParser) ParseToSQLite() (string, error) {
 	}
 	defer db.Close()
 
+	if err := p.importDump(db); err != nil {
+		os.Remove(tmpfile.Name())
+		return "", err
+	}
+	return tmpfile.Name(), nil
+}
+
+// memoryDBs numbers the in-memory databases so each import gets its own
+var memoryDBs atomic.Int64
+
+// memoryDBConns caps the connections to an in-memory database. The
+// database only lives while a connection to it is open, so every
+// connection is kept rather than closed when idle.
+const memoryDBConns = 16
+
+// ParseToMemory converts a SQL dump file to an in-memory SQLite database
+// and returns it open. Nothing is written to disk, which is faster for
+// dumps that fit in memory; the database is gone once it is closed.
+func (p *SQLDumpParser) ParseToMemory() (*sql.DB, error) {
+	// A shared cache lets every connection of the pool see the same
+	// database, where a plain :memory: database is private to one
+	dsn := fmt.Sprintf("file:sql2csv_import_%d?mode=memory&cache=shared", memoryDBs.Add(1))
+	db, err := sql.Open(string(SQLite), dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open in-memory database: %w", err)
+	}
+	db.SetMaxOpenConns(memoryDBConns)
+	db.SetMaxIdleConns(memoryDBConns)
+
+	if err := p.importDump(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// importDump reads the dump file into db
+func (p *SQLDumpParser) importDump(db *sql.DB) error {
 	// Read and process the SQL dump file
-	file, err := os.Open(p.filePath)
+	source := p.reader
+	if source == nil {
+		file, err := os.Open(p.filePath)
+		if err != nil {
+			return fmt.Errorf("failed to open SQL dump file: %w", err)
+		}
+		defer file.Close()
+		source = file
+	}
+
+	dump, err := decompressDump(source)
 	if err != nil {
-		os.Remove(tmpfile.Name())
-		return "", fmt.Errorf("failed to open SQL dump file: %w", err)
+		return err
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	var currentStatement strings.Builder
+	p.failed = nil
+	p.numericColumns = nil
+	p.constraints = nil
+	p.summary = ImportSummary{Rows: make(map[string]int64)}
+	maxLineSize := p.maxLineSize
+	if maxLineSize <= 0 {
+		maxLineSize = DefaultMaxDumpLineSize
+	}
+	scanner := bufio.NewScanner(dump)
+	scanner.Buffer(make([]byte, 0, min(64*1024, maxLineSize)), maxLineSize)
+	splitter := newStatementSplitter(p.dbType)
 	var inCopy bool
 	var copyData []string
 	var currentTable string
 	var inFunction bool
 	var inCreateTable bool
+	var createTable strings.Builder
 
 	for scanner.Scan() {
-		line := scanner.Text()
-		line = strings.TrimSpace(line)
+		// Inside a multi-line string literal the line is data, so pass it
+		// through untouched
+		if splitter.inLiteral() {
+			for _, stmt := range splitter.feed(scanner.Text()) {
+				p.execStatement(db, stmt)
+			}
+			continue
+		}
+
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+
+		// COPY data is tab separated, so its lines are kept untrimmed and
+		// may look like comments
+		if inCopy {
+			if line == "\\." {
+				// End of COPY data
+				inCopy = false
+				if err := p.insertCopyData(db, currentTable, copyData); err != nil {
+					p.summary.Failed++
+					p.logDebug("Warning: Failed to insert data into %s: %v\n", currentTable, err)
+				} else {
+					p.summary.Executed++
+				}
+				copyData = nil
+				continue
+			}
+			copyData = append(copyData, strings.TrimSuffix(raw, "\r"))
+			continue
+		}
 
 		// Skip comments and empty lines
 		if line == "" || strings.HasPrefix(line, "--") || strings.HasPrefix(line, "/*") {
@@ -98,131 +335,331 @@ func (p *SQLDumpParser) ParseToSQLite() (string, error) {
 		if strings.HasPrefix(line, "COPY ") {
 			parts := strings.Fields(line)
 			if len(parts) > 1 {
-				currentTable = strings.TrimPrefix(parts[1], "public.")
+				currentTable = strings.Trim(strings.TrimPrefix(parts[1], "public."), `"`)
 				inCopy = true
 				copyData = make([]string, 0)
 				continue
 			}
 		}
 
-		if inCopy {
-			if line == "\\." {
-				// End of COPY data
-				inCopy = false
-				if err := p.insertCopyData(db, currentTable, copyData); err != nil {
-					p.logDebug("Warning: Failed to insert data into %s: %v\n", currentTable, err)
-				}
-				copyData = nil
-				continue
+		// INSERT statements need no conversion, and converting the lines of
+		// a multi-row INSERT could change the values they hold
+		if splitter.inInsert() || isInsert(line) {
+			for _, stmt := range splitter.feed(line) {
+				p.execStatement(db, stmt)
 			}
-			copyData = append(copyData, line)
 			continue
 		}
 
-		// Handle CREATE TABLE statements
-		if strings.HasPrefix(line, "CREATE TABLE") {
-			inCreateTable = true
-			line = p.convertCreateTable(line)
+		// SQLite takes identifiers in double quotes, like the other dumps
+		if p.dbType == MySQL || p.dbType == MariaDB {
+			line = quoteBackticks(line)
 		}
 
-		// Handle end of CREATE TABLE
-		if inCreateTable && strings.Contains(line, ");") {
+		// Collect CREATE TABLE statements until they end, so their
+		// definitions can be converted one at a time
+		if inCreateTable || strings.HasPrefix(line, "CREATE TABLE") {
+			inCreateTable = true
+			createTable.WriteString(stripComment(line, splitter.backslashEscapes))
+			createTable.WriteString("\n")
+			if !createTableComplete(createTable.String(), splitter.backslashEscapes) {
+				continue
+			}
 			inCreateTable = false
-			line = p.cleanupCreateTable(line)
+			line = p.convertCreateTable(createTable.String())
+			createTable.Reset()
+			for _, stmt := range splitter.feed(line) {
+				p.execStatement(db, stmt)
+			}
+			continue
 		}
 
 		// Convert syntax for non-CREATE TABLE statements
-		if !inCreateTable {
-			line = p.convertSyntax(line)
-		}
+		line = p.convertSyntax(line)
 
 		if line == "" {
 			continue
 		}
 
-		currentStatement.WriteString(line)
-		currentStatement.WriteString(" ")
-
-		if strings.HasSuffix(line, ";") {
-			stmt := currentStatement.String()
-			if !shouldSkipStatement(stmt) {
-				// Execute the statement
-				if _, err := db.Exec(stmt); err != nil {
-					p.logDebug("Warning: Failed to execute statement: %v\nStatement: %s\n", err, stmt)
-				}
-			}
-			currentStatement.Reset()
+		for _, stmt := range splitter.feed(line) {
+			p.execStatement(db, stmt)
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		os.Remove(tmpfile.Name())
-		return "", fmt.Errorf("error reading SQL dump: %w", err)
+		if errors.Is(err, bufio.ErrTooLong) {
+			return fmt.Errorf("error reading SQL dump: a line is longer than the %d byte limit: %w", maxLineSize, err)
+		}
+		return fmt.Errorf("error reading SQL dump: %w", err)
 	}
 
-	return tmpfile.Name(), nil
+	// Dependencies of out-of-order statements may exist now
+	if p.retryFailed && len(p.failed) > 0 {
+		retry := p.failed
+		p.failed = nil
+		p.logDebug("Retrying %d failed statements\n", len(retry))
+		for _, stmt := range retry {
+			p.execStatement(db, stmt)
+		}
+	}
+
+	// The tables and their rows are all there now
+	p.applyConstraints(db)
+
+	p.summary.Failed += len(p.failed)
+	return nil
+}
+
+// decompressDump returns a reader of the dump's SQL, decompressing gzipped
+// dumps. They are recognised by their magic bytes rather than by a .gz
+// extension.
+func decompressDump(r io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(r)
+	magic, err := buffered.Peek(2)
+	if err != nil || magic[0] != 0x1f || magic[1] != 0x8b {
+		// Shorter files can't be gzipped
+		return buffered, nil
+	}
+
+	gz, err := gzip.NewReader(buffered)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gzipped SQL dump: %w", err)
+	}
+	return gz, nil
 }
 
-// convertCreateTable handles CREATE TABLE statements specifically
-func (p *SQLDumpParser) convertCreateTable(line string) string {
+// execStatement executes a complete statement unless it should be skipped
+func (p *SQLDumpParser) execStatement(db *sql.DB, stmt string) {
+	if shouldSkipStatement(stmt) {
+		p.summary.Skipped++
+		return
+	}
+	if p.deferConstraint(stmt) {
+		return
+	}
+	result, err := db.Exec(stmt)
+	if err != nil {
+		p.failed = append(p.failed, stmt)
+		p.logDebug("Warning: Failed to execute statement: %v\nStatement: %s\n", err, stmt)
+		return
+	}
+	p.summary.Executed++
+	if m := insertTablePattern.FindStringSubmatch(stmt); m != nil {
+		if rows, err := result.RowsAffected(); err == nil {
+			p.summary.Rows[strings.Trim(strings.TrimPrefix(m[1], "public."), "\"`")] += rows
+		}
+	}
+}
+
+// convertNumericColumn records exact numeric column definitions and, in
+// exact numeric mode, changes their type to TEXT
+func (p *SQLDumpParser) convertNumericColumn(table, line string) string {
+	m := numericColumnPattern.FindStringSubmatchIndex(line)
+	if m == nil {
+		return line
+	}
+
+	column := strings.Trim(line[m[2]:m[3]], "\"`")
+	p.numericColumns = append(p.numericColumns, table+"."+column)
+	if !p.exactNumeric {
+		return line
+	}
+	return line[:m[4]] + "TEXT" + line[m[5]:]
+}
+
+// convertCreateTable converts a complete CREATE TABLE statement for SQLite,
+// one column or constraint definition at a time. The table options after
+// the definitions, such as MySQL's ENGINE, are left out.
+func (p *SQLDumpParser) convertCreateTable(stmt string) string {
+	stmt = strings.TrimSpace(stmt)
+	backslashEscapes := p.dbType == MySQL || p.dbType == MariaDB
+	open := strings.IndexByte(stmt, '(')
+	end := -1
+	if open >= 0 {
+		end = closingParen(stmt, open, backslashEscapes)
+	}
+	if end < 0 {
+		// CREATE TABLE ... AS or LIKE, with nothing to convert
+		return strings.ReplaceAll(stmt, "\n", " ")
+	}
+
+	var table string
+	if m := createTablePattern.FindStringSubmatch(stmt); m != nil {
+		table = strings.Trim(strings.TrimPrefix(m[1], "public."), "\"`")
+	}
+
+	var definitions []string
+	for _, def := range splitDefinitions(stmt[open+1:end], backslashEscapes) {
+		def = strings.TrimSpace(strings.ReplaceAll(def, "\n", " "))
+		def = p.convertNumericColumn(table, def)
+		def = p.convertDefinition(def)
+		if def != "" {
+			definitions = append(definitions, def)
+		}
+	}
+
 	// Remove schema qualification
-	line = strings.ReplaceAll(line, "public.", "")
+	head := strings.ReplaceAll(strings.TrimSpace(stmt[:open]), "public.", "")
+	return head + " (" + strings.Join(definitions, ", ") + ");"
+}
+
+// createTableComplete reports whether the collected lines of a CREATE TABLE
+// statement hold all of it: the definitions, any table options and the
+// terminating semicolon
+func createTableComplete(stmt string, backslashEscapes bool) bool {
+	if !strings.HasSuffix(strings.TrimSpace(stmt), ";") {
+		return false
+	}
+	open := strings.IndexByte(stmt, '(')
+	return open < 0 || closingParen(stmt, open, backslashEscapes) >= 0
+}
+
+// convertDefinition converts a column or key definition of a MySQL CREATE
+// TABLE for SQLite. Index definitions are left out, since SQLite has no
+// inline indexes.
+func (p *SQLDumpParser) convertDefinition(line string) string {
+	if p.dbType != MySQL && p.dbType != MariaDB {
+		return line
+	}
+	if mysqlIndexPattern.MatchString(line) {
+		return ""
+	}
+	line = mysqlUniqueKeyPattern.ReplaceAllString(line, "UNIQUE (")
+	line = convertEnum(line)
+	line = mysqlCommentPattern.ReplaceAllString(line, "")
+	return mysqlAttributePattern.ReplaceAllString(line, "")
+}
+
+// convertEnum changes the type of a MySQL ENUM or SET column, which SQLite
+// lacks, to TEXT. The values aren't turned into a CHECK constraint: MySQL
+// has already validated the dumped rows, and may have stored the empty
+// string for values it rejected.
+func convertEnum(line string) string {
+	m := mysqlEnumPattern.FindStringSubmatchIndex(line)
+	if m == nil {
+		return line
+	}
+	end := closingParen(line, m[1]-1, true)
+	if end < 0 {
+		return line
+	}
+	return line[:m[3]] + "TEXT" + line[end+1:]
+}
 
-	// Convert data types
-	line = p.convertDataTypes(line)
+// closingParen returns the index of the parenthesis that closes the one at
+// open, or -1 if s doesn't close it
+func closingParen(s string, open int, backslashEscapes bool) int {
+	depth, end := 0, -1
+	scanSQL(s[open:], backslashEscapes, func(i int, c byte) bool {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				end = open + i
+				return false
+			}
+		}
+		return true
+	})
+	return end
+}
 
-	return line
+// splitDefinitions splits the body of a CREATE TABLE statement at the
+// commas between its definitions, leaving the commas of types such as
+// DECIMAL(10,2) and of key column lists alone
+func splitDefinitions(body string, backslashEscapes bool) []string {
+	var definitions []string
+	depth, start := 0, 0
+	scanSQL(body, backslashEscapes, func(i int, c byte) bool {
+		switch {
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			definitions = append(definitions, body[start:i])
+			start = i + 1
+		}
+		return true
+	})
+	return append(definitions, body[start:])
 }
 
-// cleanupCreateTable cleans up CREATE TABLE statements at their end
-func (p *SQLDumpParser) cleanupCreateTable(line string) string {
-	// Remove trailing comma before closing parenthesis
-	if idx := strings.LastIndex(line, ","); idx != -1 {
-		if strings.Index(line[idx:], ")") != -1 {
-			line = line[:idx] + line[idx+1:]
+// stripComment removes a trailing -- comment from a line
+func stripComment(line string, backslashEscapes bool) string {
+	end := len(line)
+	scanSQL(line, backslashEscapes, func(i int, c byte) bool {
+		if c == '-' && strings.HasPrefix(line[i:], "--") {
+			end = i
+			return false
+		}
+		return true
+	})
+	return line[:end]
+}
+
+// scanSQL calls fn with every character of s outside string literals and
+// quoted identifiers, with its index, until fn returns false
+func scanSQL(s string, backslashEscapes bool, fn func(i int, c byte) bool) {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '\'' && backslashEscapes {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		default:
+			if !fn(i, c) {
+				return
+			}
 		}
 	}
-	return line
-}
-
-// convertDataTypes converts PostgreSQL data types to SQLite types
-func (p *SQLDumpParser) convertDataTypes(line string) string {
-	conversions := map[string]string{
-		"SERIAL":                      "INTEGER",
-		"serial":                      "INTEGER",
-		"BIGSERIAL":                   "INTEGER",
-		"bigserial":                   "INTEGER",
-		"SMALLSERIAL":                 "INTEGER",
-		"smallserial":                 "INTEGER",
-		"timestamp without time zone": "DATETIME",
-		"timestamp with time zone":    "DATETIME",
-		"boolean":                     "BOOLEAN",
-		"BOOLEAN":                     "BOOLEAN",
-		"character varying":           "VARCHAR",
-		"varchar":                     "VARCHAR",
-		"bytea":                       "BLOB",
-		"double precision":            "REAL",
-		"bigint":                      "INTEGER",
-		"int8":                        "INTEGER",
-		"smallint":                    "INTEGER",
-		"int2":                        "INTEGER",
-		"integer":                     "INTEGER",
-		"int4":                        "INTEGER",
-		"decimal":                     "REAL",
-		"numeric":                     "REAL",
-		"text":                        "TEXT",
-		"json":                        "TEXT",
-		"jsonb":                       "TEXT",
-		"date":                        "DATE",
-	}
-
-	for pg, sqlite := range conversions {
-		pattern := fmt.Sprintf(`(?i)%s(\(\d+\))?`, regexp.QuoteMeta(pg))
-		re := regexp.MustCompile(pattern)
-		line = re.ReplaceAllString(line, sqlite)
-	}
-
-	return line
+}
+
+// quoteBackticks replaces the backticks around MySQL identifiers with double
+// quotes, leaving string literals alone
+func quoteBackticks(line string) string {
+	if !strings.Contains(line, "`") {
+		return line
+	}
+
+	var b strings.Builder
+	var inString, inIdentifier bool
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case inString:
+			if c == '\\' && i+1 < len(line) {
+				b.WriteByte(c)
+				i++
+				c = line[i]
+			} else if c == '\'' {
+				inString = false
+			}
+		case inIdentifier:
+			if c == '`' && i+1 < len(line) && line[i+1] == '`' {
+				i++ // an escaped backtick is a plain character in double quotes
+			} else if c == '`' {
+				inIdentifier = false
+				c = '"'
+			} else if c == '"' {
+				b.WriteByte('"')
+			}
+		case c == '\'':
+			inString = true
+		case c == '`':
+			inIdentifier = true
+			c = '"'
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
 }
 
 // convertSyntax converts database-specific SQL syntax to SQLite syntax
@@ -273,6 +710,8 @@ func shouldSkipStatement(stmt string) bool {
 	skipPatterns := []string{
 		`^SET `,
 		`^USE `,
+		`^LOCK TABLES`,
+		`^UNLOCK TABLES`,
 		`^ALTER DATABASE`,
 		`^CREATE DATABASE`,
 		`^CREATE SCHEMA`,
@@ -327,9 +766,13 @@ func (p *SQLDumpParser) insertCopyData(db *sql.DB, table string, data []string)
 	for i := range placeholders {
 		placeholders[i] = "?"
 	}
+	quotedColumns := make([]string, len(columns))
+	for i, column := range columns {
+		quotedColumns[i] = QuoteIdentifier(SQLite, column)
+	}
 	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
-		table,
-		strings.Join(columns, ", "),
+		QuoteIdentifier(SQLite, table),
+		strings.Join(quotedColumns, ", "),
 		strings.Join(placeholders, ", "))
 
 	stmt, err := tx.Prepare(query)
@@ -339,6 +782,7 @@ func (p *SQLDumpParser) insertCopyData(db *sql.DB, table string, data []string)
 	defer stmt.Close()
 
 	// Insert each row
+	var inserted int64
 	for _, line := range data {
 		values := p.parseCopyLine(line)
 		if len(values) != len(columns) {
@@ -346,10 +790,16 @@ func (p *SQLDumpParser) insertCopyData(db *sql.DB, table string, data []string)
 		}
 		if _, err := stmt.Exec(values...); err != nil {
 			p.logDebug("Warning: Failed to insert row into %s: %v\n", table, err)
+			continue
 		}
+		inserted++
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	p.summary.Rows[table] += inserted
+	return nil
 }
 
 // parseCopyLine parses a PostgreSQL COPY data line into values
@@ -361,14 +811,208 @@ func (p *SQLDumpParser) parseCopyLine(line string) []interface{} {
 		switch field {
 		case "\\N":
 			values = append(values, nil)
+		case "":
+			if p.emptyAsNull {
+				values = append(values, nil)
+			} else {
+				values = append(values, "")
+			}
 		case "t":
 			values = append(values, true)
 		case "f":
 			values = append(values, false)
 		default:
-			values = append(values, field)
+			values = append(values, unescapeCopyField(field))
 		}
 	}
 
 	return values
 }
+
+// unescapeCopyField decodes the backslash escapes of a COPY text field:
+// \b, \f, \n, \r, \t and \v, octal \NNN and hex \xHH bytes, and any other
+// escaped character as itself, such as the backslash
+func unescapeCopyField(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		c := field[i]
+		if c != '\\' || i+1 == len(field) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch c = field[i]; c {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case 'x':
+			n, digits := parseEscapeDigits(field[i+1:], 16, 2)
+			if digits == 0 {
+				b.WriteByte(c)
+				continue
+			}
+			b.WriteByte(n)
+			i += digits
+		default:
+			n, digits := parseEscapeDigits(field[i:], 8, 3)
+			if digits == 0 {
+				b.WriteByte(c)
+				continue
+			}
+			b.WriteByte(n)
+			i += digits - 1
+		}
+	}
+	return b.String()
+}
+
+// parseEscapeDigits reads up to max digits of the given base from the start
+// of s and returns their value and how many there were
+func parseEscapeDigits(s string, base, max int) (byte, int) {
+	var n, digits int
+	for digits < max && digits < len(s) {
+		d := strings.IndexByte("0123456789abcdef", s[digits]|0x20)
+		if d < 0 || d >= base {
+			break
+		}
+		n = n*base + d
+		digits++
+	}
+	return byte(n), digits
+}
+
+// dollarQuotePattern matches a PostgreSQL dollar-quote tag such as $$ or $body$
+var dollarQuotePattern = regexp.MustCompile(`^\$[A-Za-z_]*\$`)
+
+// isInsert reports whether a line starts an INSERT statement
+func isInsert(line string) bool {
+	return len(line) >= 6 && strings.EqualFold(line[:6], "INSERT")
+}
+
+// mysqlEscapes maps the character after a backslash in a MySQL string
+// literal to its SQLite spelling. NUL can't be part of the statement text,
+// so it is concatenated in.
+var mysqlEscapes = map[byte]string{
+	'\'': "''",
+	'"':  `"`,
+	'\\': `\`,
+	'n':  "\n",
+	'r':  "\r",
+	't':  "\t",
+	'b':  "\b",
+	'Z':  "\x1a",
+	'0':  "' || char(0) || '",
+	// MySQL keeps the backslash of these, which only escape in LIKE patterns
+	'%': `\%`,
+	'_': `\_`,
+}
+
+// statementSplitter accumulates dump lines into complete statements. A
+// semicolon only terminates a statement when it is outside string literals
+// and dollar-quoted bodies, and anything after a trailing -- is a comment.
+// Backslash escapes of MySQL dumps are rewritten for SQLite, which doesn't
+// know them.
+type statementSplitter struct {
+	current          strings.Builder
+	inString         bool
+	dollarTag        string
+	backslashEscapes bool
+}
+
+// newStatementSplitter creates a splitter for dumps of the given database type
+func newStatementSplitter(dbType DBType) *statementSplitter {
+	return &statementSplitter{
+		// MySQL dumps escape quotes inside strings with a backslash
+		backslashEscapes: dbType == MySQL || dbType == MariaDB,
+	}
+}
+
+// inLiteral reports whether the splitter is inside a quoted value that
+// continues onto the next line
+func (s *statementSplitter) inLiteral() bool {
+	return s.inString || s.dollarTag != ""
+}
+
+// inInsert reports whether the statement in progress is an INSERT
+func (s *statementSplitter) inInsert() bool {
+	return isInsert(s.current.String())
+}
+
+// feed appends a line to the current statement and returns every statement
+// the line completed
+func (s *statementSplitter) feed(line string) []string {
+	var statements []string
+	start := 0
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+
+		switch {
+		case s.dollarTag != "":
+			if strings.HasPrefix(line[i:], s.dollarTag) {
+				i += len(s.dollarTag) - 1
+				s.dollarTag = ""
+			}
+		case s.inString:
+			if c == '\\' && s.backslashEscapes && i+1 < len(line) {
+				s.current.WriteString(line[start:i])
+				if escaped, ok := mysqlEscapes[line[i+1]]; ok {
+					s.current.WriteString(escaped)
+				} else {
+					s.current.WriteByte(line[i+1])
+				}
+				i++
+				start = i + 1
+			} else if c == '\'' {
+				if i+1 < len(line) && line[i+1] == '\'' {
+					i++
+				} else {
+					s.inString = false
+				}
+			}
+		case c == '\'':
+			s.inString = true
+		case c == '$':
+			if tag := dollarQuotePattern.FindString(line[i:]); tag != "" {
+				s.dollarTag = tag
+				i += len(tag) - 1
+			}
+		case c == '-' && strings.HasPrefix(line[i:], "--"):
+			// The rest of the line is a comment
+			s.current.WriteString(line[start:i])
+			start = len(line)
+			i = len(line)
+		case c == ';':
+			s.current.WriteString(line[start : i+1])
+			if stmt := strings.TrimSpace(s.current.String()); stmt != ";" {
+				statements = append(statements, stmt)
+			}
+			s.current.Reset()
+			start = i + 1
+		}
+	}
+
+	if start < len(line) {
+		s.current.WriteString(line[start:])
+	}
+	if s.inLiteral() {
+		s.current.WriteString("\n")
+	} else if s.current.Len() > 0 {
+		s.current.WriteString(" ")
+	}
+
+	return statements
+}
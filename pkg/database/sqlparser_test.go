@@ -1,7 +1,11 @@
 package database
 
 import (
+	"compress/gzip"
+	"database/sql"
+	"fmt"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -86,6 +90,339 @@ CREATE TABLE products (
 	}
 }
 
+func TestSQLDumpParser_Gzip(t *testing.T) {
+	dumpContent := "CREATE TABLE users (\n    id INTEGER PRIMARY KEY,\n    name TEXT\n);\n" +
+		"INSERT INTO users (name) VALUES ('John Doe'), ('Jane Smith');\n"
+
+	// The magic bytes, not the name, mark the file as gzipped
+	tmpDumpFile, err := os.CreateTemp("", "test_dump_*.sql")
+	if err != nil {
+		t.Fatalf("Failed to create temp dump file: %v", err)
+	}
+	defer os.Remove(tmpDumpFile.Name())
+
+	gz := gzip.NewWriter(tmpDumpFile)
+	if _, err := gz.Write([]byte(dumpContent)); err != nil {
+		t.Fatalf("Failed to write dump content: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("Failed to close gzip stream: %v", err)
+	}
+	tmpDumpFile.Close()
+
+	parser := NewSQLDumpParser(tmpDumpFile.Name(), SQLite)
+	sqliteDBPath, err := parser.ParseToSQLite()
+	if err != nil {
+		t.Fatalf("ParseToSQLite() error = %v", err)
+	}
+	defer os.Remove(sqliteDBPath)
+
+	db, err := Connect(Config{Type: SQLite, FilePath: sqliteDBPath})
+	if err != nil {
+		t.Fatalf("Failed to connect to SQLite database: %v", err)
+	}
+	defer db.Close()
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		t.Fatalf("Failed to count rows: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("users has %d rows, want 2", count)
+	}
+}
+
+func TestSQLDumpParser_FromReader(t *testing.T) {
+	dumpContent := "CREATE TABLE users (\n    id INTEGER PRIMARY KEY,\n    name TEXT\n);\n" +
+		"INSERT INTO users (name) VALUES ('John Doe'), ('Jane Smith');\n"
+
+	parser := NewSQLDumpParserFromReader(strings.NewReader(dumpContent), SQLite)
+	sqliteDBPath, err := parser.ParseToSQLite()
+	if err != nil {
+		t.Fatalf("ParseToSQLite() error = %v", err)
+	}
+	defer os.Remove(sqliteDBPath)
+
+	if got := parser.Summary().Rows["users"]; got != 2 {
+		t.Errorf("imported %d rows into users, want 2", got)
+	}
+}
+
+func TestSQLDumpParser_ParseToMemory(t *testing.T) {
+	tmpDumpFile, err := os.CreateTemp("", "test_dump_*.sql")
+	if err != nil {
+		t.Fatalf("Failed to create temp dump file: %v", err)
+	}
+	defer os.Remove(tmpDumpFile.Name())
+	dumpContent := "CREATE TABLE users (\n    id INTEGER PRIMARY KEY,\n    name TEXT\n);\n" +
+		"INSERT INTO users (name) VALUES ('John Doe'), ('Jane Smith');\n"
+	if _, err := tmpDumpFile.WriteString(dumpContent); err != nil {
+		t.Fatalf("Failed to write dump content: %v", err)
+	}
+	tmpDumpFile.Close()
+
+	db, err := NewSQLDumpParser(tmpDumpFile.Name(), SQLite).ParseToMemory()
+	if err != nil {
+		t.Fatalf("ParseToMemory() error = %v", err)
+	}
+	defer db.Close()
+
+	// Each import gets its own database
+	other, err := NewSQLDumpParser(tmpDumpFile.Name(), SQLite).ParseToMemory()
+	if err != nil {
+		t.Fatalf("ParseToMemory() error = %v", err)
+	}
+	if _, err := other.Exec("DELETE FROM users"); err != nil {
+		t.Fatalf("Failed to delete rows: %v", err)
+	}
+	other.Close()
+
+	// Rows held open on one connection force the count onto another,
+	// which must see the same database
+	rows, err := db.Query("SELECT name FROM users ORDER BY id")
+	if err != nil {
+		t.Fatalf("Failed to query users: %v", err)
+	}
+	defer rows.Close()
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		t.Fatalf("Failed to count rows: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("users has %d rows, want 2", count)
+	}
+}
+
+func TestSQLDumpParser_Summary(t *testing.T) {
+	dumpContent := `SET client_encoding = 'UTF8';
+
+CREATE TABLE public.users (
+    id integer NOT NULL,
+    name text
+);
+
+COPY public.users (id, name) FROM stdin;
+1	a
+2	b
+3	c
+\.
+
+INSERT INTO users VALUES (4, 'd'), (5, 'e');
+INSERT INTO missing VALUES (1);
+`
+	tmpDumpFile, err := os.CreateTemp("", "test_dump_*.sql")
+	if err != nil {
+		t.Fatalf("Failed to create temp dump file: %v", err)
+	}
+	defer os.Remove(tmpDumpFile.Name())
+	if _, err := tmpDumpFile.WriteString(dumpContent); err != nil {
+		t.Fatalf("Failed to write dump content: %v", err)
+	}
+	tmpDumpFile.Close()
+
+	parser := NewSQLDumpParser(tmpDumpFile.Name(), Postgres)
+	sqliteDBPath, err := parser.ParseToSQLite()
+	if err != nil {
+		t.Fatalf("ParseToSQLite() error = %v", err)
+	}
+	defer os.Remove(sqliteDBPath)
+
+	want := ImportSummary{
+		Executed: 3, // CREATE TABLE, COPY and the first INSERT
+		Skipped:  1,
+		Failed:   1,
+		Rows:     map[string]int64{"users": 5},
+	}
+	if got := parser.Summary(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Summary() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSQLDumpParser_LongLines(t *testing.T) {
+	long := strings.Repeat("x", 100*1024)
+	create := "CREATE TABLE docs (\n    id INTEGER PRIMARY KEY,\n    body TEXT\n);\n"
+
+	tests := []struct {
+		name        string
+		dbType      DBType
+		dump        string
+		maxLineSize int
+		wantErr     bool
+	}{
+		{
+			name:   "INSERT over 64KB",
+			dbType: MySQL,
+			dump:   create + "INSERT INTO docs VALUES (1, '" + long + "');\n",
+		},
+		{
+			name:   "COPY row over 64KB",
+			dbType: Postgres,
+			dump:   create + "COPY docs (id, body) FROM stdin;\n1\t" + long + "\n\\.\n",
+		},
+		{
+			name:        "Line over the configured limit",
+			dbType:      MySQL,
+			dump:        create + "INSERT INTO docs VALUES (1, '" + long + "');\n",
+			maxLineSize: 64 * 1024,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDumpFile, err := os.CreateTemp("", "test_dump_*.sql")
+			if err != nil {
+				t.Fatalf("Failed to create temp dump file: %v", err)
+			}
+			defer os.Remove(tmpDumpFile.Name())
+			if _, err := tmpDumpFile.WriteString(tt.dump); err != nil {
+				t.Fatalf("Failed to write dump content: %v", err)
+			}
+			tmpDumpFile.Close()
+
+			parser := NewSQLDumpParser(tmpDumpFile.Name(), tt.dbType)
+			parser.SetMaxLineSize(tt.maxLineSize)
+			sqliteDBPath, err := parser.ParseToSQLite()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseToSQLite() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			defer os.Remove(sqliteDBPath)
+
+			db, err := Connect(Config{Type: SQLite, FilePath: sqliteDBPath})
+			if err != nil {
+				t.Fatalf("Failed to connect to SQLite database: %v", err)
+			}
+			defer db.Close()
+
+			var body string
+			if err := db.QueryRow("SELECT body FROM docs WHERE id = 1").Scan(&body); err != nil {
+				t.Fatalf("Failed to read row: %v", err)
+			}
+			if body != long {
+				t.Errorf("body has %d bytes, want %d", len(body), len(long))
+			}
+		})
+	}
+}
+
+func TestSQLDumpParser_KeepConstraints(t *testing.T) {
+	schema := `
+CREATE TABLE public.users (
+    id integer NOT NULL,
+    email text
+);
+
+CREATE TABLE public.orders (
+    id integer NOT NULL,
+    user_id integer
+);
+
+COPY public.users (id, email) FROM stdin;
+1	a@example.com
+%s
+\.
+
+COPY public.orders (id, user_id) FROM stdin;
+1	1
+\.
+
+ALTER TABLE ONLY public.users
+    ADD CONSTRAINT users_pkey PRIMARY KEY (id);
+
+ALTER TABLE ONLY public.users
+    ADD CONSTRAINT users_email_key UNIQUE (email);
+
+ALTER TABLE ONLY public.orders
+    ADD CONSTRAINT orders_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) NOT VALID;
+`
+
+	tests := []struct {
+		name       string
+		keep       bool
+		secondUser string
+		wantFailed int
+		wantSQL    map[string][]string // table -> fragments of its definition
+	}{
+		{
+			name:       "Constraints dropped by default",
+			secondUser: "2\tb@example.com",
+			wantFailed: 3,
+			wantSQL:    map[string][]string{"users": {"email text"}},
+		},
+		{
+			name:       "Constraints kept",
+			keep:       true,
+			secondUser: "2\tb@example.com",
+			wantSQL: map[string][]string{
+				"users":  {"CONSTRAINT users_pkey PRIMARY KEY (id)", "CONSTRAINT users_email_key UNIQUE (email)"},
+				"orders": {"CONSTRAINT orders_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id)"},
+			},
+		},
+		{
+			name:       "Violated constraints leave the table as imported",
+			keep:       true,
+			secondUser: "1\tb@example.com",
+			wantFailed: 2,
+			wantSQL:    map[string][]string{"orders": {"FOREIGN KEY (user_id)"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDumpFile, err := os.CreateTemp("", "test_dump_*.sql")
+			if err != nil {
+				t.Fatalf("Failed to create temp dump file: %v", err)
+			}
+			defer os.Remove(tmpDumpFile.Name())
+			if _, err := fmt.Fprintf(tmpDumpFile, schema, tt.secondUser); err != nil {
+				t.Fatalf("Failed to write dump content: %v", err)
+			}
+			tmpDumpFile.Close()
+
+			parser := NewSQLDumpParser(tmpDumpFile.Name(), Postgres)
+			parser.SetKeepConstraints(tt.keep)
+			sqliteDBPath, err := parser.ParseToSQLite()
+			if err != nil {
+				t.Fatalf("ParseToSQLite() error = %v", err)
+			}
+			defer os.Remove(sqliteDBPath)
+
+			if got := len(parser.FailedStatements()); got != tt.wantFailed {
+				t.Errorf("FailedStatements() has %d statements, want %d: %q", got, tt.wantFailed, parser.FailedStatements())
+			}
+
+			db, err := Connect(Config{Type: SQLite, FilePath: sqliteDBPath})
+			if err != nil {
+				t.Fatalf("Failed to connect to SQLite database: %v", err)
+			}
+			defer db.Close()
+
+			for table, fragments := range tt.wantSQL {
+				var createSQL string
+				if err := db.QueryRow("SELECT sql FROM sqlite_master WHERE name = ?", table).Scan(&createSQL); err != nil {
+					t.Fatalf("Failed to read definition of table %s: %v", table, err)
+				}
+				for _, fragment := range fragments {
+					if !strings.Contains(createSQL, fragment) {
+						t.Errorf("table %s is %q, want it to contain %q", table, createSQL, fragment)
+					}
+				}
+			}
+
+			var count int
+			if err := db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+				t.Fatalf("Failed to count rows: %v", err)
+			}
+			if count != 2 {
+				t.Errorf("users has %d rows, want 2", count)
+			}
+		})
+	}
+}
+
 func TestConvertSyntax(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -126,84 +463,306 @@ func TestConvertSyntax(t *testing.T) {
 	}
 }
 
-func TestShouldSkipStatement(t *testing.T) {
+func TestQuoteBackticks(t *testing.T) {
 	tests := []struct {
-		name      string
-		statement string
-		want      bool
+		name  string
+		input string
+		want  string
 	}{
-		{
-			name:      "CREATE TABLE",
-			statement: "CREATE TABLE users (id INT);",
-			want:      false,
-		},
-		{
-			name:      "USE statement",
-			statement: "USE database_name;",
-			want:      true,
-		},
-		{
-			name:      "Transaction statement",
-			statement: "BEGIN TRANSACTION;",
-			want:      true,
-		},
-		{
-			name:      "SET statement",
-			statement: "SET foreign_key_checks=0;",
-			want:      true,
-		},
+		{name: "Identifiers", input: "PRIMARY KEY (`id`, `order`),", want: `PRIMARY KEY ("id", "order"),`},
+		{name: "Escaped backtick", input: "`a``b` int", want: "\"a`b\" int"},
+		{name: "Double quote in name", input: "`say \"hi\"` int", want: `"say ""hi""" int`},
+		{name: "Backtick in a string", input: "`note` varchar(10) DEFAULT 'it\\'s `x`'", want: `"note" varchar(10) DEFAULT 'it\'s ` + "`x`'"},
+		{name: "No backticks", input: `"id" integer`, want: `"id" integer`},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := shouldSkipStatement(tt.statement)
-			if got != tt.want {
-				t.Errorf("shouldSkipStatement() = %v, want %v", got, tt.want)
+			if got := quoteBackticks(tt.input); got != tt.want {
+				t.Errorf("quoteBackticks() = %s, want %s", got, tt.want)
 			}
 		})
 	}
 }
 
-func TestSQLDumpParser_ErrorHandling(t *testing.T) {
+func TestSQLDumpParser_MySQLDump(t *testing.T) {
+	// A table as mysqldump writes it
+	dumpContent := "DROP TABLE IF EXISTS `order`;\n" +
+		"/*!40101 SET @saved_cs_client     = @@character_set_client */;\n" +
+		"/*!50503 SET character_set_client = utf8mb4 */;\n" +
+		"CREATE TABLE `order` (\n" +
+		"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n" +
+		"  `customer name` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci DEFAULT NULL,\n" +
+		"  `email` varchar(255) NOT NULL,\n" +
+		"  `updated_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  UNIQUE KEY `email` (`email`) USING BTREE,\n" +
+		"  KEY `idx_name` (`customer name`)\n" +
+		") ENGINE=InnoDB AUTO_INCREMENT=3 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;\n" +
+		"/*!40101 SET character_set_client = @saved_cs_client */;\n\n" +
+		"LOCK TABLES `order` WRITE;\n" +
+		"/*!40000 ALTER TABLE `order` DISABLE KEYS */;\n" +
+		"INSERT INTO `order` VALUES (1,'Ann','ann@example.com','2024-01-01 00:00:00'),(2,'Bob','bob@example.com','2024-01-02 00:00:00');\n" +
+		"/*!40000 ALTER TABLE `order` ENABLE KEYS */;\n" +
+		"UNLOCK TABLES;\n"
+
+	parser := NewSQLDumpParserFromReader(strings.NewReader(dumpContent), MySQL)
+	db, err := parser.ParseToMemory()
+	if err != nil {
+		t.Fatalf("ParseToMemory() error = %v", err)
+	}
+	defer db.Close()
+
+	if summary := parser.Summary(); summary.Failed != 0 || summary.Rows["order"] != 2 {
+		t.Errorf("Summary() = %+v, want no failures and 2 rows in order", summary)
+	}
+
+	columns, err := GetColumns(db, SQLite, "order")
+	if err != nil {
+		t.Fatalf("GetColumns() error = %v", err)
+	}
+	if want := "id,customer name,email,updated_at"; strings.Join(columns, ",") != want {
+		t.Errorf("GetColumns() = %v, want %s", columns, want)
+	}
+
+	// The unique key is kept
+	if _, err := db.Exec(`INSERT INTO "order" VALUES (3, 'Cy', 'ann@example.com', '2024-01-03 00:00:00')`); err == nil {
+		t.Error("inserting a duplicate email succeeded, want a unique constraint error")
+	}
+}
+
+func TestConvertEnum(t *testing.T) {
 	tests := []struct {
-		name        string
-		dumpContent string
-		wantErr     bool
-		errContains string
+		name  string
+		input string
+		want  string
 	}{
 		{
-			name: "Invalid SQL syntax",
-			dumpContent: `
-				CREATE TABLE users (
-					id INTEGER PRIMARY,
-					name TEXT,
-				);
-			`,
-			wantErr:     false, // We don't fail on SQL errors, just log warnings
-			errContains: "",
+			name:  "ENUM",
+			input: `"status" enum('new','paid') NOT NULL DEFAULT 'new',`,
+			want:  `"status" TEXT NOT NULL DEFAULT 'new',`,
 		},
 		{
-			name: "Valid SQL",
-			dumpContent: `
-				CREATE TABLE users (
-					id INTEGER PRIMARY KEY,
-					name TEXT
-				);
-			`,
-			wantErr: false,
+			name:  "SET",
+			input: `tags SET('a','b,c') DEFAULT NULL,`,
+			want:  `tags TEXT DEFAULT NULL,`,
+		},
+		{
+			name:  "Parentheses and quotes in values",
+			input: `"size" enum('s (small)','it\'s )',''),`,
+			want:  `"size" TEXT,`,
+		},
+		{
+			name:  "Other type",
+			input: `"setting" varchar(10),`,
+			want:  `"setting" varchar(10),`,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Create temporary dump file
-			tmpDumpFile, err := os.CreateTemp("", "test_dump_*.sql")
-			if err != nil {
-				t.Fatalf("Failed to create temp dump file: %v", err)
+			if got := convertEnum(tt.input); got != tt.want {
+				t.Errorf("convertEnum() = %s, want %s", got, tt.want)
 			}
-			defer os.Remove(tmpDumpFile.Name())
+		})
+	}
+}
 
-			if _, err := tmpDumpFile.WriteString(tt.dumpContent); err != nil {
+func TestSQLDumpParser_MySQLEnum(t *testing.T) {
+	dumpContent := "CREATE TABLE `orders` (\n" +
+		"  `id` int NOT NULL,\n" +
+		"  `status` enum('new','paid','shipped') NOT NULL DEFAULT 'new',\n" +
+		"  `flags` set('gift','express') DEFAULT NULL,\n" +
+		"  PRIMARY KEY (`id`)\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;\n" +
+		"INSERT INTO `orders` VALUES (1,'paid','gift,express'),(2,'',NULL);\n"
+
+	parser := NewSQLDumpParserFromReader(strings.NewReader(dumpContent), MySQL)
+	db, err := parser.ParseToMemory()
+	if err != nil {
+		t.Fatalf("ParseToMemory() error = %v", err)
+	}
+	defer db.Close()
+
+	if summary := parser.Summary(); summary.Failed != 0 || summary.Rows["orders"] != 2 {
+		t.Errorf("Summary() = %+v, want no failures and 2 rows in orders", summary)
+	}
+
+	var status, flags string
+	if err := db.QueryRow(`SELECT status, flags FROM orders WHERE id = 1`).Scan(&status, &flags); err != nil {
+		t.Fatalf("Failed to read row: %v", err)
+	}
+	if status != "paid" || flags != "gift,express" {
+		t.Errorf("row = (%s, %s), want (paid, gift,express)", status, flags)
+	}
+}
+
+func TestSplitDefinitions(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{name: "Columns", body: "id int, name text", want: []string{"id int", " name text"}},
+		{name: "Type with precision", body: "price DECIMAL(10,2), qty int", want: []string{"price DECIMAL(10,2)", " qty int"}},
+		{name: "Key column list", body: "a int, PRIMARY KEY (a, b)", want: []string{"a int", " PRIMARY KEY (a, b)"}},
+		{name: "Quoted commas", body: `"a,b" text DEFAULT 'x, y', c int`, want: []string{`"a,b" text DEFAULT 'x, y'`, " c int"}},
+		{name: "Trailing comma", body: "a int,", want: []string{"a int", ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitDefinitions(tt.body, true)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("splitDefinitions() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLDumpParser_CreateTableDefinitions(t *testing.T) {
+	tests := []struct {
+		name   string
+		dbType DBType
+		dump   string
+	}{
+		{
+			name:   "Trailing comments",
+			dbType: Postgres,
+			dump: "CREATE TABLE products (\n" +
+				"    id integer NOT NULL, -- the id (unique)\n" +
+				"    price DECIMAL(10,2) NOT NULL, -- in EUR);\n" +
+				"    note text DEFAULT 'a, b); -- c'\n" +
+				");\n",
+		},
+		{
+			name:   "MySQL column comments",
+			dbType: MySQL,
+			dump: "CREATE TABLE `products` (\n" +
+				"  `id` int NOT NULL COMMENT 'the id (unique)',\n" +
+				"  `price` decimal(10,2) NOT NULL COMMENT 'in EUR, it\\'s gross);',\n" +
+				"  `note` varchar(20) DEFAULT 'a, b); -- c',\n" +
+				"  PRIMARY KEY (`id`)\n" +
+				") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='products; all of them';\n",
+		},
+		{
+			name:   "Single line",
+			dbType: MySQL,
+			dump:   "CREATE TABLE `products` (`id` int, `price` decimal(10,2), `note` varchar(20) DEFAULT 'a, b); -- c') ENGINE=InnoDB;\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dump := tt.dump + "INSERT INTO products (id, price) VALUES (1, 9.99);\n"
+			parser := NewSQLDumpParserFromReader(strings.NewReader(dump), tt.dbType)
+			db, err := parser.ParseToMemory()
+			if err != nil {
+				t.Fatalf("ParseToMemory() error = %v", err)
+			}
+			defer db.Close()
+
+			if failed := parser.FailedStatements(); len(failed) > 0 {
+				t.Errorf("FailedStatements() = %q, want none", failed)
+			}
+			columns, err := GetColumns(db, SQLite, "products")
+			if err != nil {
+				t.Fatalf("GetColumns() error = %v", err)
+			}
+			if want := "id,price,note"; strings.Join(columns, ",") != want {
+				t.Errorf("GetColumns() = %v, want %s", columns, want)
+			}
+
+			var price float64
+			var note string
+			if err := db.QueryRow(`SELECT price, note FROM products`).Scan(&price, &note); err != nil {
+				t.Fatalf("Failed to read row: %v", err)
+			}
+			if price != 9.99 || note != "a, b); -- c" {
+				t.Errorf("row = (%v, %q), want (9.99, %q)", price, note, "a, b); -- c")
+			}
+		})
+	}
+}
+
+func TestShouldSkipStatement(t *testing.T) {
+	tests := []struct {
+		name      string
+		statement string
+		want      bool
+	}{
+		{
+			name:      "CREATE TABLE",
+			statement: "CREATE TABLE users (id INT);",
+			want:      false,
+		},
+		{
+			name:      "USE statement",
+			statement: "USE database_name;",
+			want:      true,
+		},
+		{
+			name:      "Transaction statement",
+			statement: "BEGIN TRANSACTION;",
+			want:      true,
+		},
+		{
+			name:      "SET statement",
+			statement: "SET foreign_key_checks=0;",
+			want:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := shouldSkipStatement(tt.statement)
+			if got != tt.want {
+				t.Errorf("shouldSkipStatement() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLDumpParser_ErrorHandling(t *testing.T) {
+	tests := []struct {
+		name        string
+		dumpContent string
+		wantErr     bool
+		errContains string
+	}{
+		{
+			name: "Invalid SQL syntax",
+			dumpContent: `
+				CREATE TABLE users (
+					id INTEGER PRIMARY,
+					name TEXT,
+				);
+			`,
+			wantErr:     false, // We don't fail on SQL errors, just log warnings
+			errContains: "",
+		},
+		{
+			name: "Valid SQL",
+			dumpContent: `
+				CREATE TABLE users (
+					id INTEGER PRIMARY KEY,
+					name TEXT
+				);
+			`,
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create temporary dump file
+			tmpDumpFile, err := os.CreateTemp("", "test_dump_*.sql")
+			if err != nil {
+				t.Fatalf("Failed to create temp dump file: %v", err)
+			}
+			defer os.Remove(tmpDumpFile.Name())
+
+			if _, err := tmpDumpFile.WriteString(tt.dumpContent); err != nil {
 				t.Fatalf("Failed to write dump content: %v", err)
 			}
 			tmpDumpFile.Close()
@@ -228,3 +787,458 @@ func TestSQLDumpParser_ErrorHandling(t *testing.T) {
 		})
 	}
 }
+
+func TestSQLDumpParser_CopyIntoReservedWordTable(t *testing.T) {
+	dumpContent := "CREATE TABLE public.\"order\" (\n    id integer,\n    total numeric\n);\n\n" +
+		"COPY public.\"order\" (id, total) FROM stdin;\n1\t9.99\n2\t19.99\n\\.\n"
+
+	tmpDumpFile, err := os.CreateTemp("", "test_dump_*.sql")
+	if err != nil {
+		t.Fatalf("Failed to create temp dump file: %v", err)
+	}
+	defer os.Remove(tmpDumpFile.Name())
+
+	if _, err := tmpDumpFile.WriteString(dumpContent); err != nil {
+		t.Fatalf("Failed to write dump content: %v", err)
+	}
+	tmpDumpFile.Close()
+
+	parser := NewSQLDumpParser(tmpDumpFile.Name(), Postgres)
+	sqliteDBPath, err := parser.ParseToSQLite()
+	if err != nil {
+		t.Fatalf("ParseToSQLite() error = %v", err)
+	}
+	defer os.Remove(sqliteDBPath)
+
+	db, err := Connect(Config{Type: SQLite, FilePath: sqliteDBPath})
+	if err != nil {
+		t.Fatalf("Failed to connect to SQLite database: %v", err)
+	}
+	defer db.Close()
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM "order"`).Scan(&count); err != nil {
+		t.Fatalf("Failed to count rows: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Imported %d rows, want 2", count)
+	}
+}
+
+func TestStatementSplitter(t *testing.T) {
+	tests := []struct {
+		name   string
+		dbType DBType
+		lines  []string
+		want   []string
+	}{
+		{
+			name:  "Semicolon inside string",
+			lines: []string{"INSERT INTO notes VALUES (1, 'first; second');"},
+			want:  []string{"INSERT INTO notes VALUES (1, 'first; second');"},
+		},
+		{
+			name:  "String ending a line with a semicolon",
+			lines: []string{"INSERT INTO notes VALUES (1, 'ends with;", "more');"},
+			want:  []string{"INSERT INTO notes VALUES (1, 'ends with;\nmore');"},
+		},
+		{
+			name:  "Inline comment after semicolon",
+			lines: []string{"INSERT INTO notes VALUES (1, 'a'); -- first row"},
+			want:  []string{"INSERT INTO notes VALUES (1, 'a');"},
+		},
+		{
+			name:  "Two statements on one line",
+			lines: []string{"INSERT INTO a VALUES (1); INSERT INTO b VALUES (2);"},
+			want:  []string{"INSERT INTO a VALUES (1);", "INSERT INTO b VALUES (2);"},
+		},
+		{
+			name:  "Escaped quote",
+			lines: []string{"INSERT INTO notes VALUES ('it''s; fine');"},
+			want:  []string{"INSERT INTO notes VALUES ('it''s; fine');"},
+		},
+		{
+			name:   "MySQL backslash escape",
+			dbType: MySQL,
+			lines:  []string{`INSERT INTO notes VALUES ('it\'s; fine');`},
+			want:   []string{`INSERT INTO notes VALUES ('it''s; fine');`},
+		},
+		{
+			name:   "MySQL escaped newline and backslash",
+			dbType: MySQL,
+			lines:  []string{`INSERT INTO notes VALUES ('a\nb', 'C:\\temp\\', '50\%');`},
+			want:   []string{"INSERT INTO notes VALUES ('a\nb', 'C:\\temp\\', '50\\%');"},
+		},
+		{
+			name:  "Dollar quoting",
+			lines: []string{"DO $body$ BEGIN", "PERFORM 1;", "END $body$;"},
+			want:  []string{"DO $body$ BEGIN\nPERFORM 1;\nEND $body$;"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			splitter := newStatementSplitter(tt.dbType)
+			var got []string
+			for _, line := range tt.lines {
+				got = append(got, splitter.feed(line)...)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("feed() = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("statement %d = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSQLDumpParser_SemicolonInString(t *testing.T) {
+	dumpContent := `
+CREATE TABLE notes (
+    id INTEGER PRIMARY KEY,
+    body TEXT
+);
+
+INSERT INTO notes (id, body) VALUES (1, 'first; second'); -- inline comment
+INSERT INTO notes (id, body) VALUES (2, 'ends with;
+more');
+`
+	tmpDumpFile, err := os.CreateTemp("", "test_dump_*.sql")
+	if err != nil {
+		t.Fatalf("Failed to create temp dump file: %v", err)
+	}
+	defer os.Remove(tmpDumpFile.Name())
+
+	if _, err := tmpDumpFile.WriteString(dumpContent); err != nil {
+		t.Fatalf("Failed to write dump content: %v", err)
+	}
+	tmpDumpFile.Close()
+
+	parser := NewSQLDumpParser(tmpDumpFile.Name(), SQLite)
+	sqliteDBPath, err := parser.ParseToSQLite()
+	if err != nil {
+		t.Fatalf("ParseToSQLite() error = %v", err)
+	}
+	defer os.Remove(sqliteDBPath)
+
+	db, err := Connect(Config{Type: SQLite, FilePath: sqliteDBPath})
+	if err != nil {
+		t.Fatalf("Failed to connect to SQLite database: %v", err)
+	}
+	defer db.Close()
+
+	want := map[int]string{1: "first; second", 2: "ends with;\nmore"}
+	for id, body := range want {
+		var got string
+		if err := db.QueryRow("SELECT body FROM notes WHERE id = ?", id).Scan(&got); err != nil {
+			t.Fatalf("Failed to read row %d: %v", id, err)
+		}
+		if got != body {
+			t.Errorf("Row %d body = %q, want %q", id, got, body)
+		}
+	}
+}
+
+func TestSQLDumpParser_MultiRowInsert(t *testing.T) {
+	dumpContent := "CREATE TABLE `notes` (\n" +
+		"  `id` int NOT NULL,\n" +
+		"  `body` text\n" +
+		");\n" +
+		"INSERT INTO `notes` VALUES (1,'first; second'),\n" +
+		"(2,'two  spaces COLLATE utf8 OWNER TO ops'),\n" +
+		"(3,'it\\'s; \\\\ done'),\n" +
+		"(4,'line one\\nline two');\n"
+
+	tmpDumpFile, err := os.CreateTemp("", "test_dump_*.sql")
+	if err != nil {
+		t.Fatalf("Failed to create temp dump file: %v", err)
+	}
+	defer os.Remove(tmpDumpFile.Name())
+
+	if _, err := tmpDumpFile.WriteString(dumpContent); err != nil {
+		t.Fatalf("Failed to write dump content: %v", err)
+	}
+	tmpDumpFile.Close()
+
+	parser := NewSQLDumpParser(tmpDumpFile.Name(), MySQL)
+	sqliteDBPath, err := parser.ParseToSQLite()
+	if err != nil {
+		t.Fatalf("ParseToSQLite() error = %v", err)
+	}
+	defer os.Remove(sqliteDBPath)
+	if failed := parser.FailedStatements(); len(failed) > 0 {
+		t.Fatalf("FailedStatements() = %q, want none", failed)
+	}
+
+	db, err := Connect(Config{Type: SQLite, FilePath: sqliteDBPath})
+	if err != nil {
+		t.Fatalf("Failed to connect to SQLite database: %v", err)
+	}
+	defer db.Close()
+
+	want := map[int]string{
+		1: "first; second",
+		2: "two  spaces COLLATE utf8 OWNER TO ops",
+		3: `it's; \ done`,
+		4: "line one\nline two",
+	}
+	for id, body := range want {
+		var got string
+		if err := db.QueryRow("SELECT body FROM notes WHERE id = ?", id).Scan(&got); err != nil {
+			t.Fatalf("Failed to read row %d: %v", id, err)
+		}
+		if got != body {
+			t.Errorf("Row %d body = %q, want %q", id, got, body)
+		}
+	}
+}
+
+func TestSQLDumpParser_RetryFailed(t *testing.T) {
+	// The INSERT comes before the table it targets
+	dumpContent := `
+INSERT INTO late (id, name) VALUES (1, 'x');
+CREATE TABLE late (
+    id INTEGER PRIMARY KEY,
+    name TEXT
+);
+INSERT INTO missing (id) VALUES (1);
+`
+	tmpDumpFile, err := os.CreateTemp("", "test_dump_*.sql")
+	if err != nil {
+		t.Fatalf("Failed to create temp dump file: %v", err)
+	}
+	defer os.Remove(tmpDumpFile.Name())
+
+	if _, err := tmpDumpFile.WriteString(dumpContent); err != nil {
+		t.Fatalf("Failed to write dump content: %v", err)
+	}
+	tmpDumpFile.Close()
+
+	tests := []struct {
+		name       string
+		retry      bool
+		wantRows   int
+		wantFailed int
+	}{
+		{name: "Single pass", retry: false, wantRows: 0, wantFailed: 2},
+		{name: "Retry failed", retry: true, wantRows: 1, wantFailed: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewSQLDumpParser(tmpDumpFile.Name(), SQLite)
+			parser.SetRetryFailed(tt.retry)
+			sqliteDBPath, err := parser.ParseToSQLite()
+			if err != nil {
+				t.Fatalf("ParseToSQLite() error = %v", err)
+			}
+			defer os.Remove(sqliteDBPath)
+
+			db, err := Connect(Config{Type: SQLite, FilePath: sqliteDBPath})
+			if err != nil {
+				t.Fatalf("Failed to connect to SQLite database: %v", err)
+			}
+			defer db.Close()
+
+			var rows int
+			if err := db.QueryRow("SELECT COUNT(*) FROM late").Scan(&rows); err != nil {
+				t.Fatalf("Failed to count rows: %v", err)
+			}
+			if rows != tt.wantRows {
+				t.Errorf("late has %d rows, want %d", rows, tt.wantRows)
+			}
+			if failed := parser.FailedStatements(); len(failed) != tt.wantFailed {
+				t.Errorf("FailedStatements() = %q, want %d statements", failed, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestSQLDumpParser_ExactNumeric(t *testing.T) {
+	dumpContent := "CREATE TABLE public.accounts (\n    id integer,\n    amount numeric(20,2) NOT NULL,\n" +
+		"    rate DECIMAL\n);\n\n" +
+		"COPY public.accounts (id, amount, rate) FROM stdin;\n1\t12345678901234567.89\t1.10\n\\.\n"
+
+	tmpDumpFile, err := os.CreateTemp("", "test_dump_*.sql")
+	if err != nil {
+		t.Fatalf("Failed to create temp dump file: %v", err)
+	}
+	defer os.Remove(tmpDumpFile.Name())
+
+	if _, err := tmpDumpFile.WriteString(dumpContent); err != nil {
+		t.Fatalf("Failed to write dump content: %v", err)
+	}
+	tmpDumpFile.Close()
+
+	tests := []struct {
+		name      string
+		exact     bool
+		wantExact bool
+	}{
+		// SQLite's NUMERIC affinity rounds the value to a float
+		{name: "Default", exact: false, wantExact: false},
+		{name: "Exact numeric", exact: true, wantExact: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewSQLDumpParser(tmpDumpFile.Name(), Postgres)
+			parser.SetExactNumeric(tt.exact)
+			sqliteDBPath, err := parser.ParseToSQLite()
+			if err != nil {
+				t.Fatalf("ParseToSQLite() error = %v", err)
+			}
+			defer os.Remove(sqliteDBPath)
+
+			want := []string{"accounts.amount", "accounts.rate"}
+			if got := parser.NumericColumns(); strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("NumericColumns() = %v, want %v", got, want)
+			}
+
+			db, err := Connect(Config{Type: SQLite, FilePath: sqliteDBPath})
+			if err != nil {
+				t.Fatalf("Failed to connect to SQLite database: %v", err)
+			}
+			defer db.Close()
+
+			var amount string
+			if err := db.QueryRow(`SELECT CAST(amount AS TEXT) FROM accounts`).Scan(&amount); err != nil {
+				t.Fatalf("Failed to read row: %v", err)
+			}
+			if exact := amount == "12345678901234567.89"; exact != tt.wantExact {
+				t.Errorf("amount = %s, exact = %v, want exact = %v", amount, exact, tt.wantExact)
+			}
+		})
+	}
+}
+
+func TestUnescapeCopyField(t *testing.T) {
+	tests := []struct {
+		name  string
+		field string
+		want  string
+	}{
+		{name: "Plain", field: "plain text", want: "plain text"},
+		{name: "Tab", field: `a\tb`, want: "a\tb"},
+		{name: "Newline and carriage return", field: `one\r\ntwo`, want: "one\r\ntwo"},
+		{name: "Backslash", field: `C:\\dir\\`, want: `C:\dir\`},
+		{name: "Octal", field: `\101\0`, want: "A\x00"},
+		{name: "Hex", field: `\x41\x4a!`, want: "AJ!"},
+		{name: "Other escaped character", field: `\"quoted\"`, want: `"quoted"`},
+		{name: "Trailing backslash", field: `end\`, want: `end\`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unescapeCopyField(tt.field); got != tt.want {
+				t.Errorf("unescapeCopyField(%q) = %q, want %q", tt.field, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLDumpParser_CopyEscapes(t *testing.T) {
+	dumpContent := "CREATE TABLE public.files (\n    id integer,\n    path text,\n    note text\n);\n\n" +
+		"COPY public.files (id, path, note) FROM stdin;\n" +
+		"1\tC:\\\\temp\\\\new\tcol\\tsep\n" +
+		"2\t-- not a comment\t  padded  \n" +
+		"3\t\tline\\nbreak\n" +
+		"\\.\n"
+
+	tmpDumpFile, err := os.CreateTemp("", "test_dump_*.sql")
+	if err != nil {
+		t.Fatalf("Failed to create temp dump file: %v", err)
+	}
+	defer os.Remove(tmpDumpFile.Name())
+
+	if _, err := tmpDumpFile.WriteString(dumpContent); err != nil {
+		t.Fatalf("Failed to write dump content: %v", err)
+	}
+	tmpDumpFile.Close()
+
+	parser := NewSQLDumpParser(tmpDumpFile.Name(), Postgres)
+	sqliteDBPath, err := parser.ParseToSQLite()
+	if err != nil {
+		t.Fatalf("ParseToSQLite() error = %v", err)
+	}
+	defer os.Remove(sqliteDBPath)
+
+	db, err := Connect(Config{Type: SQLite, FilePath: sqliteDBPath})
+	if err != nil {
+		t.Fatalf("Failed to connect to SQLite database: %v", err)
+	}
+	defer db.Close()
+
+	want := map[int][2]string{
+		1: {`C:\temp\new`, "col\tsep"},
+		2: {"-- not a comment", "  padded  "},
+		3: {"", "line\nbreak"},
+	}
+	for id, values := range want {
+		var path, note string
+		if err := db.QueryRow("SELECT path, note FROM files WHERE id = ?", id).Scan(&path, &note); err != nil {
+			t.Fatalf("Failed to read row %d: %v", id, err)
+		}
+		if path != values[0] || note != values[1] {
+			t.Errorf("Row %d = %q, %q, want %q, %q", id, path, note, values[0], values[1])
+		}
+	}
+}
+
+func TestSQLDumpParser_EmptyAsNull(t *testing.T) {
+	dumpContent := "CREATE TABLE public.people (\n    id integer,\n    name text,\n    note text\n);\n\n" +
+		"COPY public.people (id, name, note) FROM stdin;\n1\t\t\\N\n\\.\n"
+
+	tmpDumpFile, err := os.CreateTemp("", "test_dump_*.sql")
+	if err != nil {
+		t.Fatalf("Failed to create temp dump file: %v", err)
+	}
+	defer os.Remove(tmpDumpFile.Name())
+
+	if _, err := tmpDumpFile.WriteString(dumpContent); err != nil {
+		t.Fatalf("Failed to write dump content: %v", err)
+	}
+	tmpDumpFile.Close()
+
+	tests := []struct {
+		name        string
+		emptyAsNull bool
+		wantName    sql.NullString
+	}{
+		{name: "Empty string", emptyAsNull: false, wantName: sql.NullString{String: "", Valid: true}},
+		{name: "Empty as NULL", emptyAsNull: true, wantName: sql.NullString{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewSQLDumpParser(tmpDumpFile.Name(), Postgres)
+			parser.SetEmptyAsNull(tt.emptyAsNull)
+			sqliteDBPath, err := parser.ParseToSQLite()
+			if err != nil {
+				t.Fatalf("ParseToSQLite() error = %v", err)
+			}
+			defer os.Remove(sqliteDBPath)
+
+			db, err := Connect(Config{Type: SQLite, FilePath: sqliteDBPath})
+			if err != nil {
+				t.Fatalf("Failed to connect to SQLite database: %v", err)
+			}
+			defer db.Close()
+
+			var name, note sql.NullString
+			if err := db.QueryRow(`SELECT name, note FROM people`).Scan(&name, &note); err != nil {
+				t.Fatalf("Failed to read row: %v", err)
+			}
+			if name != tt.wantName {
+				t.Errorf("name = %+v, want %+v", name, tt.wantName)
+			}
+			// \N is always NULL
+			if note.Valid {
+				t.Errorf("note = %q, want NULL", note.String)
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+//go:build cgo
+
+package duckdb
+
+// The DuckDB driver is a cgo wrapper around the DuckDB library, so it is
+// only available in cgo builds
+import _ "github.com/marcboeker/go-duckdb"
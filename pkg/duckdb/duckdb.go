@@ -0,0 +1,155 @@
+// Package duckdb loads exported tables into a DuckDB database file
+package duckdb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sql2csv/pkg/database"
+	"sql2csv/pkg/exporter"
+	"strings"
+)
+
+const driverName = "duckdb"
+
+// Loader writes source tables into a DuckDB database
+type Loader struct {
+	db *sql.DB
+}
+
+// Open opens or creates the DuckDB database at path
+func Open(path string) (*Loader, error) {
+	if !slices.Contains(sql.Drivers(), driverName) {
+		return nil, errors.New("DuckDB support requires a build with cgo enabled")
+	}
+
+	db, err := sql.Open(driverName, path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening DuckDB database: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error opening DuckDB database: %w", err)
+	}
+
+	return &Loader{db: db}, nil
+}
+
+// Close closes the DuckDB database
+func (l *Loader) Close() error {
+	return l.db.Close()
+}
+
+// TableWriter returns a writer that replaces the named table with the rows
+// of an export
+func (l *Loader) TableWriter(table string) exporter.RowWriter {
+	return &tableWriter{db: l.db, table: table}
+}
+
+// tableWriter creates a DuckDB table from the export's column types and
+// inserts each batch in its own transaction
+type tableWriter struct {
+	db      *sql.DB
+	table   string
+	types   []string
+	columns string
+	insert  string
+}
+
+func (w *tableWriter) WriteHeader(columns []string, types []*sql.ColumnType) error {
+	w.types = make([]string, len(columns))
+	definitions := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	for i, column := range columns {
+		w.types[i] = "VARCHAR"
+		if i < len(types) {
+			w.types[i] = duckDBType(types[i])
+		}
+		definitions[i] = quoteIdentifier(column) + " " + w.types[i]
+		placeholders[i] = "?"
+	}
+
+	create := fmt.Sprintf("CREATE OR REPLACE TABLE %s (%s)",
+		quoteIdentifier(w.table), strings.Join(definitions, ", "))
+	if _, err := w.db.Exec(create); err != nil {
+		return fmt.Errorf("error creating DuckDB table: %w", err)
+	}
+
+	w.insert = fmt.Sprintf("INSERT INTO %s VALUES (%s)",
+		quoteIdentifier(w.table), strings.Join(placeholders, ", "))
+	return nil
+}
+
+func (w *tableWriter) WriteBatch(rows [][]interface{}) error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(w.insert)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		args := make([]interface{}, len(row))
+		for i, val := range row {
+			// Drivers return text as bytes, which DuckDB would store as a BLOB
+			if b, ok := val.([]byte); ok && w.types[i] != "BLOB" {
+				val = string(b)
+			}
+			args[i] = val
+		}
+		if _, err := stmt.Exec(args...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (w *tableWriter) Close() error {
+	return nil
+}
+
+// quoteIdentifier quotes a name for DuckDB, which follows the PostgreSQL
+// quoting rules
+func quoteIdentifier(name string) string {
+	return database.QuoteIdentifier(database.Postgres, name)
+}
+
+// duckDBType maps a source column type onto the closest DuckDB type, falling
+// back to VARCHAR
+func duckDBType(ct *sql.ColumnType) string {
+	name := strings.ToUpper(ct.DatabaseTypeName())
+	name = strings.TrimPrefix(name, "UNSIGNED ")
+	if i := strings.IndexByte(name, '('); i != -1 {
+		name = strings.TrimSpace(name[:i])
+	}
+
+	switch name {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "BIGINT", "INT2", "INT4", "INT8", "YEAR":
+		return "BIGINT"
+	case "REAL", "FLOAT", "FLOAT4", "FLOAT8", "DOUBLE", "DOUBLE PRECISION":
+		return "DOUBLE"
+	case "DECIMAL", "NUMERIC":
+		if precision, scale, ok := ct.DecimalSize(); ok && precision > 0 && precision <= 38 {
+			return fmt.Sprintf("DECIMAL(%d,%d)", precision, scale)
+		}
+		return "DOUBLE"
+	case "BOOL", "BOOLEAN":
+		return "BOOLEAN"
+	case "DATE":
+		return "DATE"
+	case "DATETIME", "TIMESTAMP", "TIMESTAMPTZ":
+		return "TIMESTAMP"
+	case "BLOB", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB", "BINARY", "VARBINARY", "BYTEA":
+		return "BLOB"
+	default:
+		return "VARCHAR"
+	}
+}
@@ -0,0 +1,90 @@
+package duckdb
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sql2csv/pkg/exporter"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestLoader_LoadTable(t *testing.T) {
+	// Create a temporary SQLite database as the source
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	src, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer src.Close()
+
+	_, err = src.Exec(`
+		CREATE TABLE test_table (
+			id INTEGER PRIMARY KEY,
+			name TEXT,
+			score REAL,
+			data BLOB
+		);
+		INSERT INTO test_table (name, score, data) VALUES
+		('John Doe', 1.5, X'00ff'),
+		(NULL, NULL, NULL);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	outputDir, err := os.MkdirTemp("", "duckdb_output")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	loader, err := Open(filepath.Join(outputDir, "out.duckdb"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer loader.Close()
+
+	exp := exporter.NewTableExporter(src, "test_table", []string{"id", "name", "score", "data"}, outputDir)
+	if err := exp.ExportTo(loader.TableWriter("test_table")); err != nil {
+		t.Fatalf("ExportTo() error = %v", err)
+	}
+
+	var count int
+	if err := loader.db.QueryRow(`SELECT COUNT(*) FROM test_table`).Scan(&count); err != nil {
+		t.Fatalf("Failed to count rows: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Loaded %d rows, want 2", count)
+	}
+
+	var id int64
+	var name string
+	var score float64
+	var data []byte
+	err = loader.db.QueryRow(`SELECT id, name, score, data FROM test_table WHERE name IS NOT NULL`).
+		Scan(&id, &name, &score, &data)
+	if err != nil {
+		t.Fatalf("Failed to read loaded row: %v", err)
+	}
+	if id != 1 || name != "John Doe" || score != 1.5 || string(data) != "\x00\xff" {
+		t.Errorf("Loaded row = (%d, %q, %v, %x), want (1, \"John Doe\", 1.5, 00ff)", id, name, score, data)
+	}
+
+	// Loading again replaces the table rather than appending
+	if err := exp.ExportTo(loader.TableWriter("test_table")); err != nil {
+		t.Fatalf("ExportTo() error = %v", err)
+	}
+	if err := loader.db.QueryRow(`SELECT COUNT(*) FROM test_table`).Scan(&count); err != nil {
+		t.Fatalf("Failed to count rows: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Reloaded %d rows, want 2", count)
+	}
+}
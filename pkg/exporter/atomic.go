@@ -0,0 +1,57 @@
+package exporter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// atomicFile is a temporary file next to its destination that replaces the
+// destination on commit, so readers never see a partially written file
+type atomicFile struct {
+	*os.File
+	path string
+}
+
+// createAtomic creates the temporary file for path. A failed export leaves
+// an existing file at path untouched.
+func createAtomic(path string, onTempFile func(path string)) (*atomicFile, error) {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("error creating output file: %w", err)
+	}
+	if onTempFile != nil {
+		onTempFile(file.Name())
+	}
+	// CreateTemp only grants access to the owner
+	if err := file.Chmod(0644); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("error creating output file: %w", err)
+	}
+	return &atomicFile{File: file, path: path}, nil
+}
+
+// commit closes the temporary file and renames it to the destination
+func (f *atomicFile) commit() error {
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("error closing output file: %w", err)
+	}
+	return f.rename()
+}
+
+// rename moves the closed temporary file to the destination
+func (f *atomicFile) rename() error {
+	if err := os.Rename(f.Name(), f.path); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("error renaming output file: %w", err)
+	}
+	return nil
+}
+
+// abort closes and removes the temporary file
+func (f *atomicFile) abort() {
+	f.Close()
+	os.Remove(f.Name())
+}
@@ -0,0 +1,99 @@
+package exporter
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestTableExporter_FailedExportLeavesNoFile(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE test_table (id INTEGER PRIMARY KEY, name TEXT);
+		INSERT INTO test_table (name) VALUES ('a'), ('b'), ('c');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	// Fail the scan of the second row
+	originalScanRow := scanRow
+	defer func() { scanRow = originalScanRow }()
+	calls := 0
+	scanRow = func(rows *sql.Rows, dest []interface{}) error {
+		calls++
+		if calls == 2 {
+			return errors.New("corrupt row")
+		}
+		return originalScanRow(rows, dest)
+	}
+
+	tests := []struct {
+		name     string
+		existing string
+	}{
+		{name: "No previous file"},
+		{name: "Previous file kept", existing: "id,name\n1,old\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputDir, err := os.MkdirTemp("", "csv_output")
+			if err != nil {
+				t.Fatalf("Failed to create temp directory: %v", err)
+			}
+			defer os.RemoveAll(outputDir)
+
+			exp := NewTableExporter(db, "test_table", []string{"id", "name"}, outputDir)
+			if tt.existing != "" {
+				if err := os.WriteFile(exp.OutputPath(), []byte(tt.existing), 0644); err != nil {
+					t.Fatalf("Failed to write existing file: %v", err)
+				}
+			}
+			var tempPath string
+			exp.OnTempFile = func(path string) { tempPath = path }
+
+			calls = 0
+			if err := exp.Export(); err == nil {
+				t.Fatal("Export() expected error, got nil")
+			}
+
+			if tempPath == "" || filepath.Dir(tempPath) != outputDir {
+				t.Errorf("OnTempFile got %q, want a file in %s", tempPath, outputDir)
+			}
+			entries, err := os.ReadDir(outputDir)
+			if err != nil {
+				t.Fatalf("Failed to read output directory: %v", err)
+			}
+			if tt.existing == "" {
+				if len(entries) != 0 {
+					t.Errorf("output directory has %d files after a failed export, want none", len(entries))
+				}
+				return
+			}
+			content, err := os.ReadFile(exp.OutputPath())
+			if err != nil {
+				t.Fatalf("Failed to read existing file: %v", err)
+			}
+			if len(entries) != 1 || string(content) != tt.existing {
+				t.Errorf("output directory has %d files and %q, want only the untouched previous file", len(entries), content)
+			}
+		})
+	}
+}
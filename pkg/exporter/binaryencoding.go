@@ -0,0 +1,45 @@
+package exporter
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"unicode/utf8"
+)
+
+// BinaryEncoding selects how the values of binary columns, such as BLOB and
+// bytea, are written to CSV output
+type BinaryEncoding string
+
+const (
+	BinaryRaw    BinaryEncoding = "raw"
+	BinaryHex    BinaryEncoding = "hex"
+	BinaryBase64 BinaryEncoding = "base64"
+)
+
+// ParseBinaryEncoding validates a binary encoding; the empty string means
+// BinaryRaw
+func ParseBinaryEncoding(name string) (BinaryEncoding, error) {
+	switch encoding := BinaryEncoding(name); encoding {
+	case "", BinaryRaw, BinaryHex, BinaryBase64:
+		return encoding, nil
+	}
+	return "", fmt.Errorf("unknown binary encoding %q, want raw, hex or base64", name)
+}
+
+// encode returns the text of a value of a column, encoded if it is binary:
+// bytes of a binary column, or bytes that aren't text. ok is false for any
+// other value, which is then written as usual.
+func (b BinaryEncoding) encode(v interface{}, binaryColumn bool) (s string, ok bool) {
+	data, isBytes := v.([]byte)
+	if !isBytes || (!binaryColumn && utf8.Valid(data)) {
+		return "", false
+	}
+	switch b {
+	case BinaryHex:
+		return hex.EncodeToString(data), true
+	case BinaryBase64:
+		return base64.StdEncoding.EncodeToString(data), true
+	}
+	return "", false
+}
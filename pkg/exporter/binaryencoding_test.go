@@ -0,0 +1,106 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestBinaryEncoding_Encode(t *testing.T) {
+	tests := []struct {
+		name         string
+		encoding     BinaryEncoding
+		value        interface{}
+		binaryColumn bool
+		want         string
+		wantOK       bool
+	}{
+		{name: "Hex", encoding: BinaryHex, value: []byte{0xca, 0xfe}, binaryColumn: true, want: "cafe", wantOK: true},
+		{name: "Base64", encoding: BinaryBase64, value: []byte{0xca, 0xfe}, binaryColumn: true, want: "yv4=", wantOK: true},
+		{name: "Raw", encoding: BinaryRaw, value: []byte{0xca, 0xfe}, binaryColumn: true},
+		{name: "Text bytes of a binary column", encoding: BinaryHex, value: []byte("ab"), binaryColumn: true, want: "6162", wantOK: true},
+		{name: "Text bytes of a text column", encoding: BinaryHex, value: []byte("ab")},
+		{name: "Invalid UTF-8 of a text column", encoding: BinaryHex, value: []byte{0xff}, want: "ff", wantOK: true},
+		{name: "Empty value", encoding: BinaryBase64, value: []byte{}, binaryColumn: true, wantOK: true},
+		{name: "String", encoding: BinaryHex, value: "ab", binaryColumn: true},
+		{name: "NULL", encoding: BinaryHex, value: nil, binaryColumn: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.encoding.encode(tt.value, tt.binaryColumn)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("encode() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseBinaryEncoding(t *testing.T) {
+	for _, name := range []string{"", "raw", "hex", "base64"} {
+		if _, err := ParseBinaryEncoding(name); err != nil {
+			t.Errorf("ParseBinaryEncoding(%q) error = %v", name, err)
+		}
+	}
+	for _, name := range []string{"HEX", "base32", "b64"} {
+		if _, err := ParseBinaryEncoding(name); err == nil {
+			t.Errorf("ParseBinaryEncoding(%q) error = nil, want an error", name)
+		}
+	}
+}
+
+func TestTableExporter_BinaryEncoding(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE files (id INTEGER PRIMARY KEY, name TEXT, data BLOB);
+		INSERT INTO files (name, data) VALUES ('logo', x'89504e470d0a'), ('none', NULL);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	tests := []struct {
+		encoding BinaryEncoding
+		want     string
+	}{
+		{encoding: BinaryHex, want: "id,name,data\n1,logo,89504e470d0a\n2,none,\n"},
+		{encoding: BinaryBase64, want: "id,name,data\n1,logo,iVBORw0K\n2,none,\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.encoding), func(t *testing.T) {
+			exp := NewTableExporter(db, "files", []string{"id", "name", "data"}, "")
+			exp.BinaryEncoding = tt.encoding
+			var buf bytes.Buffer
+			if err := exp.ExportStream(context.Background(), &buf); err != nil {
+				t.Fatalf("ExportStream() error = %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("ExportStream() wrote %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+
+	exp := NewTableExporter(db, "files", []string{"id"}, "")
+	exp.BinaryEncoding = BinaryHex
+	exp.Format = JSON
+	if _, err := exp.newRowWriter(nil); err == nil {
+		t.Error("newRowWriter() for JSON with a binary encoding succeeded, want an error")
+	}
+}
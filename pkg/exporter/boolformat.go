@@ -0,0 +1,110 @@
+package exporter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BoolFormat selects how boolean values are written to CSV output: one of
+// the named formats, or any two spellings given as TRUE/FALSE, e.g. Y/N
+type BoolFormat string
+
+const (
+	BoolTrueFalse BoolFormat = "true-false"
+	BoolOneZero   BoolFormat = "1-0"
+	BoolYesNo     BoolFormat = "yes-no"
+)
+
+// ParseBoolFormat validates a boolean format; the empty string keeps the
+// driver's rendering
+func ParseBoolFormat(name string) (BoolFormat, error) {
+	switch format := BoolFormat(name); format {
+	case "", BoolTrueFalse, BoolOneZero, BoolYesNo:
+		return format, nil
+	}
+
+	t, f, ok := strings.Cut(name, "/")
+	if !ok {
+		return "", fmt.Errorf("unknown boolean format %q, want true-false, 1-0, yes-no or two spellings such as TRUE/FALSE", name)
+	}
+	if t == "" || f == "" || t == f || strings.Contains(f, "/") {
+		return "", fmt.Errorf("boolean format %q needs two different spellings separated by one /", name)
+	}
+	return BoolFormat(name), nil
+}
+
+// spellings returns the text written for true and for false
+func (f BoolFormat) spellings() (string, string) {
+	switch f {
+	case BoolOneZero:
+		return "1", "0"
+	case BoolYesNo:
+		return "yes", "no"
+	case BoolTrueFalse:
+		return "true", "false"
+	}
+	if yes, no, ok := strings.Cut(string(f), "/"); ok {
+		return yes, no
+	}
+	return "true", "false"
+}
+
+// isBoolType reports whether a driver type name is a boolean type. MySQL
+// reports BOOLEAN columns as TINYINT, so they can't be told apart from
+// numbers.
+func isBoolType(name string) bool {
+	switch strings.ToUpper(name) {
+	case "BOOL", "BOOLEAN", "BIT":
+		return true
+	}
+	return false
+}
+
+// format returns the text of a boolean value: a Go bool, or for columns of
+// a boolean type the 0/1 or t/f forms drivers return. ok is false for any
+// other value, which is then written as usual.
+func (f BoolFormat) format(v interface{}, boolColumn bool) (s string, ok bool) {
+	var b bool
+	switch v := v.(type) {
+	case bool:
+		b = v
+	case int64:
+		if !boolColumn || (v != 0 && v != 1) {
+			return "", false
+		}
+		b = v == 1
+	case []byte:
+		// MySQL returns BIT(1) as a single byte
+		if boolColumn && len(v) == 1 && v[0] <= 1 {
+			b = v[0] == 1
+		} else if b, ok = parseBoolText(string(v), boolColumn); !ok {
+			return "", false
+		}
+	case string:
+		if b, ok = parseBoolText(v, boolColumn); !ok {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+
+	yes, no := f.spellings()
+	if b {
+		return yes, true
+	}
+	return no, true
+}
+
+// parseBoolText reads the text forms of booleans in boolean columns
+func parseBoolText(s string, boolColumn bool) (b, ok bool) {
+	if !boolColumn {
+		return false, false
+	}
+	switch strings.ToLower(s) {
+	case "1", "t", "true":
+		return true, true
+	case "0", "f", "false":
+		return false, true
+	}
+	return false, false
+}
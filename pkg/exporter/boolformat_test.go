@@ -0,0 +1,98 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestBoolFormat_Format(t *testing.T) {
+	tests := []struct {
+		name       string
+		format     BoolFormat
+		value      interface{}
+		boolColumn bool
+		want       string
+		wantOK     bool
+	}{
+		{name: "Go true as true-false", format: BoolTrueFalse, value: true, want: "true", wantOK: true},
+		{name: "Go false as true-false", format: BoolTrueFalse, value: false, want: "false", wantOK: true},
+		{name: "Go true as 1-0", format: BoolOneZero, value: true, want: "1", wantOK: true},
+		{name: "Go false as 1-0", format: BoolOneZero, value: false, want: "0", wantOK: true},
+		{name: "Go true as yes-no", format: BoolYesNo, value: true, want: "yes", wantOK: true},
+		{name: "Go false as yes-no", format: BoolYesNo, value: false, want: "no", wantOK: true},
+		{name: "Go true as TRUE/FALSE", format: "TRUE/FALSE", value: true, want: "TRUE", wantOK: true},
+		{name: "Driver int64 0 as Y/N", format: "Y/N", value: int64(0), boolColumn: true, want: "N", wantOK: true},
+		{name: "Go bool outside a boolean column", format: BoolYesNo, value: true, want: "yes", wantOK: true},
+		{name: "Driver int64 1", format: BoolTrueFalse, value: int64(1), boolColumn: true, want: "true", wantOK: true},
+		{name: "Driver int64 0", format: BoolYesNo, value: int64(0), boolColumn: true, want: "no", wantOK: true},
+		{name: "Driver int64 outside a boolean column", format: BoolYesNo, value: int64(1)},
+		{name: "Driver int64 out of range", format: BoolYesNo, value: int64(2), boolColumn: true},
+		{name: "Postgres text t", format: BoolOneZero, value: []byte("t"), boolColumn: true, want: "1", wantOK: true},
+		{name: "Postgres text f", format: BoolOneZero, value: "f", boolColumn: true, want: "0", wantOK: true},
+		{name: "MySQL BIT(1)", format: BoolTrueFalse, value: []byte{1}, boolColumn: true, want: "true", wantOK: true},
+		{name: "Text outside a boolean column", format: BoolTrueFalse, value: "t"},
+		{name: "NULL", format: BoolTrueFalse, value: nil, boolColumn: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.format.format(tt.value, tt.boolColumn)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("format() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseBoolFormat(t *testing.T) {
+	for _, name := range []string{"", "true-false", "1-0", "yes-no", "TRUE/FALSE", "t/f", "on/off"} {
+		if _, err := ParseBoolFormat(name); err != nil {
+			t.Errorf("ParseBoolFormat(%q) error = %v", name, err)
+		}
+	}
+	for _, name := range []string{"on-off", "TRUE/", "/FALSE", "x/x", "a/b/c"} {
+		if _, err := ParseBoolFormat(name); err == nil {
+			t.Errorf("ParseBoolFormat(%q) error = nil, want an error", name)
+		}
+	}
+}
+
+func TestTableExporter_BoolFormat(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE flags (id INTEGER PRIMARY KEY, active BOOLEAN);
+		INSERT INTO flags (active) VALUES (1), (0), (NULL);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	exp := NewTableExporter(db, "flags", []string{"id", "active"}, "")
+	exp.BoolFormat = BoolYesNo
+	exp.NullString = `\N`
+	var buf bytes.Buffer
+	if err := exp.ExportStream(context.Background(), &buf); err != nil {
+		t.Fatalf("ExportStream() error = %v", err)
+	}
+
+	if want := "id,active\n1,yes\n2,no\n3,\\N\n"; buf.String() != want {
+		t.Errorf("ExportStream() wrote %q, want %q", buf.String(), want)
+	}
+}
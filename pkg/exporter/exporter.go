@@ -1,61 +1,545 @@
 package exporter
 
 import (
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
 	"database/sql"
 	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sql2csv/pkg/database"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
-const batchSize = 1000
+// DefaultBatchSize is the number of rows written at once unless BatchSize
+// says otherwise
+const DefaultBatchSize = 1000
+
+// DefaultTimeLayout is the layout of time values in CSV output unless
+// TimeLayout says otherwise
+const DefaultTimeLayout = time.RFC3339
+
+// RowHashColumn is the column RowHash appends to the export
+const RowHashColumn = "__row_hash"
+
+// identifierPattern matches identifiers that are safe to substitute into a
+// query template
+var identifierPattern = regexp.MustCompile(`^[\p{L}\p{N}_$.]+$`)
+
+// scanRow reads the current row into dest; it is a variable so tests can
+// simulate scan failures
+var scanRow = func(rows *sql.Rows, dest []interface{}) error {
+	return rows.Scan(dest...)
+}
+
+// ErrReadTimeout is the cause of exports cancelled because no row arrived
+// within ReadTimeout
+var ErrReadTimeout = errors.New("read timed out")
+
+// Format selects the file format a table is exported to
+type Format string
+
+const (
+	CSV  Format = "csv"
+	SQL  Format = "sql"
+	JSON Format = "json"
+	// JSONL writes one JSON object per line, without an enclosing array
+	JSONL Format = "jsonl"
+	// XLSX writes an Excel workbook with one worksheet
+	XLSX Format = "xlsx"
+)
 
 // TableExporter handles the export of a single table to CSV
 type TableExporter struct {
 	db        *sql.DB
 	tableName string
 	columns   []string
-	output    string
+	outputDir string
+
+	// QueryTemplate, when set, is used to build the export query instead of
+	// the fixed SELECT. It supports the {columns}, {table}, {where}, {order}
+	// and {limit} placeholders and must contain at least {table}.
+	QueryTemplate string
+
+	// FileNameTemplate, when set, names the output file instead of the table
+	// name, e.g. {db}_{table}_{date}. It supports the {table}, {db}, {date}
+	// and {time} placeholders and must contain at least {table}; the
+	// extension of the format is appended.
+	FileNameTemplate string
+
+	// DatabaseName replaces {db} in FileNameTemplate
+	DatabaseName string
+
+	// FileNameTime is the time whose date (2006-01-02) and local time
+	// (150405) replace {date} and {time} in FileNameTemplate. Defaults to
+	// the time the exporter was created.
+	FileNameTime time.Time
+
+	// IncludeColumns limits the export to these columns, kept in table
+	// order. Every name must exist in the table.
+	IncludeColumns []string
+
+	// ExcludeColumns removes these columns from the export
+	ExcludeColumns []string
+
+	// Where, when set, restricts the export to rows matching this SQL
+	// predicate. It is inserted verbatim as "WHERE <Where>"; the caller is
+	// responsible for its correctness and for not passing untrusted input.
+	Where string
+
+	// OrderBy, when set, sorts the exported rows by this SQL ORDER BY list,
+	// e.g. "id" or "created_at DESC, id", so repeated exports can be
+	// diffed. Like Where it is inserted verbatim. Incremental exports are
+	// always ordered by IncrementalColumn and can't have one.
+	OrderBy string
+
+	// TableSample, when greater than zero, exports about this percentage of
+	// the rows. Postgres samples whole pages with TABLESAMPLE SYSTEM, which
+	// is fast but less uniform since rows stored together are kept or
+	// skipped together; other databases, and Postgres views, which
+	// TABLESAMPLE doesn't accept, pick rows at random with a WHERE
+	// condition, which still reads the whole table. Requires DBType.
+	TableSample float64
+
+	// View marks the exported table as a view
+	View bool
+
+	// Limit caps the number of exported rows with a LIMIT clause when
+	// greater than zero
+	Limit int
+
+	// Expressions replaces the SELECT expression of the named columns, e.g.
+	// to have the server convert a binary type to text. Each expression
+	// should alias the result back to the column name.
+	Expressions map[string]string
+
+	// Joins adds columns of other tables to the export. Joined columns are
+	// named with JoinColumnName and follow the column they are joined on;
+	// IncludeColumns and ExcludeColumns don't apply to them. The exported
+	// table's columns are qualified with its name in the query, so Where
+	// can refer to either table.
+	Joins []Join
+
+	// JSONColumns names columns whose values must be valid JSON. They are
+	// written in compact form.
+	JSONColumns []string
+
+	// Format is the output file format. Defaults to CSV.
+	Format Format
+
+	// PrettyJSON indents the objects of the JSON format over several lines
+	// instead of writing one per line. JSONL always has one per line.
+	PrettyJSON bool
+
+	// Dialect is the database type whose quoting rules are used for the SQL
+	// format's INSERT statements
+	Dialect database.DBType
+
+	// DBType is the type of the database being read. When set, table and
+	// column names are quoted in the export query, so reserved words and
+	// names with spaces work; otherwise they are used verbatim.
+	DBType database.DBType
+
+	// Delimiter is the CSV field separator. Defaults to ','; a tab produces
+	// a .tsv file.
+	Delimiter rune
+
+	// NullString is written for NULL values in CSV output. Defaults to an
+	// empty string; \N or NULL keep NULLs apart from empty strings.
+	NullString string
+
+	// ColumnNullStrings overrides NullString for the named columns, e.g. to
+	// write 0 for missing numbers
+	ColumnNullStrings map[string]string
+
+	// BoolFormat, when set, writes the booleans of CSV output in this form,
+	// whatever the driver returns for them. Booleans are Go bool values and
+	// 0/1 or t/f values of BOOL, BOOLEAN and BIT columns.
+	BoolFormat BoolFormat
+
+	// BinaryEncoding, when hex or base64, encodes the values of binary
+	// columns in CSV output, and any other values the driver returns as
+	// bytes that aren't valid UTF-8, so they survive as text. Empty or raw
+	// writes the bytes unchanged.
+	BinaryEncoding BinaryEncoding
+
+	// TimeLayout is the layout, as for time.Format, of the time values of
+	// CSV output, e.g. "2006-01-02 15:04:05"; empty means DefaultTimeLayout
+	TimeLayout string
+
+	// Schema, when set, lists the columns the export must have. The export
+	// fails with a *SchemaError before any row is written if they differ.
+	Schema []SchemaColumn
+
+	// Compress gzips the output file and appends .gz to its name
+	Compress bool
+
+	// MaxRowsPerFile, when greater than zero, splits the export into files
+	// of at most this many rows, named with PartPath, each starting with the
+	// header. Zero writes a single file.
+	MaxRowsPerFile int
+
+	// MaxBytesPerFile, when greater than zero, splits the export like
+	// MaxRowsPerFile, starting a new file when the next row would take the
+	// current one past this many bytes. A row larger than the limit gets a
+	// file of its own. With Compress the limit applies to the data before
+	// compression, so files end up smaller. Not supported for XLSX output
+	// or with RepeatHeader.
+	MaxBytesPerFile int64
+
+	// IncrementalColumn, when set, appends to an existing CSV file instead of
+	// replacing it, exporting only rows whose value in this column is
+	// greater than the one on the file's last line. The column must be
+	// non-NULL and increase with every new row; rows are read in its order.
+	IncrementalColumn string
+
+	// ColumnComments, when non-nil, adds a second CSV header row holding
+	// each column's comment, blank for columns without one
+	ColumnComments map[string]string
+
+	// RepeatHeader, when greater than zero, writes the header rows of CSV
+	// output again after every RepeatHeader data rows, for files read in a
+	// pager. CSV readers take the repeated headers for data rows.
+	RepeatHeader int
+
+	// ExternalTextThreshold, when greater than zero, writes text values
+	// longer than this many bytes to files in the ExternalTextDir directory
+	// of the output directory, named <table>_<key>_<column>.txt, and exports
+	// their relative path instead. Requires PrimaryKey.
+	ExternalTextThreshold int
+
+	// PrimaryKey names the table's primary key columns, used to name
+	// external text files, which requires them to be exported, and by
+	// OrderByPrimaryKey
+	PrimaryKey []string
+
+	// OrderByPrimaryKey orders exports without OrderBy by PrimaryKey, so
+	// tables that have one come out in the same order on every run. Query
+	// templates are only ordered at their {order} placeholder.
+	OrderByPrimaryKey bool
+
+	// RowHash appends a RowHashColumn holding the SHA-256 of the row's
+	// formatted values, so rows can be compared between exports. The hash
+	// only depends on the values and the column order.
+	RowHash bool
+
+	// ReadTimeout, when greater than zero, fails the export if no row
+	// arrives for this long. The timer starts with the query and restarts
+	// after every row, so slow scans that keep producing rows never trip it.
+	ReadTimeout time.Duration
+
+	// BatchSize is the number of rows buffered before they are written.
+	// Smaller batches use less memory on wide tables, larger ones are faster
+	// on narrow tables. Values below 1 mean DefaultBatchSize.
+	BatchSize int
+
+	// MemoryLimit, when greater than zero, adapts the batch size to the
+	// process's heap: batches shrink while the heap is above this many bytes,
+	// and the export pauses for the garbage collector if that isn't enough.
+	// Batches grow back to BatchSize once memory is freed.
+	MemoryLimit uint64
+
+	// OnProgress, when set, is called with the number of rows written after
+	// every batch and once more when the export ends. Calls for one export
+	// never overlap.
+	OnProgress func(rowsWritten int64)
+
+	// OnTempFile, when set, is called with the path of the temporary file an
+	// export is written to before it is renamed to OutputPath, so callers
+	// can remove it if the process is aborted
+	OnTempFile func(path string)
+
+	// SkipBadRows logs and skips rows that fail to scan instead of aborting
+	// the export
+	SkipBadRows bool
+
+	stats Stats
+
+	// joinExprs holds the SELECT expressions of the joined columns, see
+	// addJoinColumns
+	joinExprs map[string]string
+
+	// progress, when set, is called after every row read
+	progress func()
+
+	// parts holds the paths of the files of a split export, see OutputPaths
+	parts []string
+
+	// State of an incremental export, see loadWatermark
+	appending    bool
+	watermark    string
+	hasWatermark bool
+}
+
+// Stats summarises the outcome of an export
+type Stats struct {
+	Rows        int64            // data rows written
+	SkippedRows int64            // rows skipped because they failed to scan
+	NullCounts  map[string]int64 // NULL values written per column
+	Elapsed     time.Duration    // from sending the query to flushing the output
+}
+
+// RowWriter receives the rows of an export in batches. The built-in formats
+// write to a file; other destinations can be plugged in through ExportTo.
+type RowWriter interface {
+	// WriteHeader is called once with the exported columns and the driver's
+	// column types before any rows are written
+	WriteHeader(columns []string, types []*sql.ColumnType) error
+	WriteBatch(rows [][]interface{}) error
+	Close() error
 }
 
 // NewTableExporter creates a new TableExporter instance
 func NewTableExporter(db *sql.DB, tableName string, columns []string, outputDir string) *TableExporter {
 	return &TableExporter{
-		db:        db,
-		tableName: tableName,
-		columns:   columns,
-		output:    filepath.Join(outputDir, fmt.Sprintf("%s.csv", tableName)),
+		db:           db,
+		tableName:    tableName,
+		columns:      columns,
+		outputDir:    outputDir,
+		Format:       CSV,
+		FileNameTime: time.Now(),
 	}
 }
 
-// Export exports the table to a CSV file
+// Stats returns the row counts of the last export
+func (e *TableExporter) Stats() Stats {
+	return e.stats
+}
+
+// OutputPath returns the path of the file the table is exported to
+func (e *TableExporter) OutputPath() string {
+	name := fmt.Sprintf("%s.%s", e.fileName(), e.extension())
+	if e.Compress {
+		name += ".gz"
+	}
+	return filepath.Join(e.outputDir, name)
+}
+
+// format returns the configured output format, defaulting to CSV
+func (e *TableExporter) format() Format {
+	if e.Format == "" {
+		return CSV
+	}
+	return e.Format
+}
+
+// extension returns the output file extension for the configured format
+func (e *TableExporter) extension() string {
+	if e.format() == CSV && e.Delimiter == '\t' {
+		return "tsv"
+	}
+	return string(e.format())
+}
+
+// delimiter returns the configured CSV delimiter after validating it
+func (e *TableExporter) delimiter() (rune, error) {
+	if e.Delimiter == 0 {
+		return ',', nil
+	}
+	if e.Delimiter == '\n' || e.Delimiter == '\r' || e.Delimiter == '"' ||
+		e.Delimiter == utf8.RuneError || !utf8.ValidRune(e.Delimiter) {
+		return 0, fmt.Errorf("invalid CSV delimiter: %q", e.Delimiter)
+	}
+	return e.Delimiter, nil
+}
+
+// Export exports the table to a file in the configured format
 func (e *TableExporter) Export() error {
-	file, err := os.Create(e.output)
+	return e.ExportContext(context.Background())
+}
+
+// ExportContext is like Export but stops early when ctx is done. Rows read
+// before the cancellation are still flushed, leaving a valid partial file.
+// The export is written to a temporary file that only replaces OutputPath
+// once it is complete or ctx's deadline has passed; when ctx is cancelled
+// or on any other error it is removed, keeping the previous export.
+// Incremental exports append to OutputPath directly, and exports split with
+// MaxRowsPerFile or MaxBytesPerFile write the PartPath files instead.
+func (e *TableExporter) ExportContext(ctx context.Context) error {
+	if err := e.filterColumns(); err != nil {
+		return err
+	}
+
+	// Validate the options before creating the output file
+	if _, err := e.newRowWriter(io.Discard); err != nil {
+		return err
+	}
+	if err := CheckFileNameTemplate(e.FileNameTemplate); err != nil {
+		return err
+	}
+	if e.ExternalTextThreshold > 0 {
+		if _, err := e.externalTextKeys(); err != nil {
+			return err
+		}
+	}
+
+	if e.split() {
+		return e.exportParts(ctx)
+	}
+
+	if e.IncrementalColumn != "" {
+		if err := e.loadWatermark(); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(e.OutputPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+		if err != nil {
+			return fmt.Errorf("error creating output file: %w", err)
+		}
+		defer file.Close()
+		return e.ExportStream(ctx, file)
+	}
+
+	file, err := createAtomic(e.OutputPath(), e.OnTempFile)
 	if err != nil {
-		return fmt.Errorf("error creating output file: %w", err)
+		return err
+	}
+	err = e.ExportStream(ctx, file)
+	if err != nil && !keepPartial(ctx) {
+		file.abort()
+		return err
+	}
+	if commitErr := file.commit(); commitErr != nil {
+		return commitErr
 	}
-	defer file.Close()
+	return err
+}
+
+// keepPartial reports whether the partial export of a done ctx replaces
+// the previous one: only when it ran out of time, not when it was cancelled
+func keepPartial(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), context.DeadlineExceeded)
+}
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+// ExportStream writes the table in the configured format to w, e.g.
+// os.Stdout, gzipping it when Compress is set. Like ExportContext, it stops
+// early when ctx is done.
+func (e *TableExporter) ExportStream(ctx context.Context, w io.Writer) error {
+	if err := e.filterColumns(); err != nil {
+		return err
+	}
+	if e.split() {
+		return fmt.Errorf("splitting an export into files requires file output")
+	}
 
-	// Write header
-	if err := writer.Write(e.columns); err != nil {
-		return fmt.Errorf("error writing header: %w", err)
+	var out io.Writer = w
+	var gz *gzip.Writer
+	if e.Compress {
+		gz = gzip.NewWriter(w)
+		out = gz
+	}
+
+	writer, err := e.newRowWriter(out)
+	if err != nil {
+		return err
+	}
+
+	exportErr := e.ExportToContext(ctx, writer)
+
+	// The gzip stream must be closed before the file for the archive to be
+	// complete, including after an interrupted export. Close doesn't close
+	// the underlying writer.
+	if gz != nil {
+		if err := gz.Close(); err != nil && exportErr == nil {
+			return fmt.Errorf("error closing gzip stream: %w", err)
+		}
+	}
+
+	return exportErr
+}
+
+// ExportTo streams the table's rows to the given writer
+func (e *TableExporter) ExportTo(writer RowWriter) error {
+	return e.ExportToContext(context.Background(), writer)
+}
+
+// ExportToContext streams the table's rows to the given writer until ctx is
+// done. An interrupted export still writes and closes what was read and
+// returns an error wrapping the cancellation cause: ctx.Err(), or
+// ErrReadTimeout.
+func (e *TableExporter) ExportToContext(ctx context.Context, writer RowWriter) error {
+	e.stats = Stats{}
+
+	if err := e.filterColumns(); err != nil {
+		return err
+	}
+
+	// Cancel the query when it stops producing rows
+	if e.ReadTimeout > 0 {
+		var cancel context.CancelCauseFunc
+		ctx, cancel = context.WithCancelCause(ctx)
+		defer cancel(nil)
+		watchdog := time.AfterFunc(e.ReadTimeout, func() {
+			cancel(fmt.Errorf("%w: no row received for %s", ErrReadTimeout, e.ReadTimeout))
+		})
+		defer watchdog.Stop()
+		e.progress = func() { watchdog.Reset(e.ReadTimeout) }
+		defer func() { e.progress = nil }()
 	}
 
 	// Prepare the query
-	query := fmt.Sprintf("SELECT %s FROM %s",
-		strings.Join(e.columns, ", "),
-		e.tableName)
+	query, err := e.buildQuery()
+	if err != nil {
+		return err
+	}
 
-	rows, err := e.db.Query(query)
+	started := time.Now()
+	rows, err := e.db.QueryContext(ctx, query)
 	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("export interrupted: %w", context.Cause(ctx))
+		}
 		return fmt.Errorf("error querying data: %w", err)
 	}
 	defer rows.Close()
 
+	err = e.writeRows(ctx, rows, writer)
+	e.stats.Elapsed = time.Since(started)
+	return err
+}
+
+// writeRows streams the result set to writer in batches and closes it
+func (e *TableExporter) writeRows(ctx context.Context, rows *sql.Rows, writer RowWriter) error {
+	e.stats = Stats{NullCounts: make(map[string]int64, len(e.columns))}
+	for _, column := range e.columns {
+		e.stats.NullCounts[column] = 0
+	}
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return fmt.Errorf("error reading column types: %w", err)
+	}
+
+	if e.RowHash {
+		for _, column := range e.columns {
+			if column == RowHashColumn {
+				return fmt.Errorf("table %s already has a %s column", e.tableName, RowHashColumn)
+			}
+		}
+	}
+
+	if e.Schema != nil {
+		if err := validateSchema(e.tableName, e.Schema, e.headerColumns(), types); err != nil {
+			return err
+		}
+	}
+
+	// Write header
+	if err := writer.WriteHeader(e.headerColumns(), types); err != nil {
+		return fmt.Errorf("error writing header: %w", err)
+	}
+
 	// Prepare the value holders for scanning
 	values := make([]interface{}, len(e.columns))
 	valuePtrs := make([]interface{}, len(e.columns))
@@ -63,52 +547,608 @@ func (e *TableExporter) Export() error {
 		valuePtrs[i] = &values[i]
 	}
 
+	jsonIdx := e.jsonColumnIndexes()
+
+	var external *textExternalizer
+	if e.ExternalTextThreshold > 0 {
+		if external, err = e.newTextExternalizer(types); err != nil {
+			return err
+		}
+	}
+
 	// Process rows in batches
-	batch := make([][]string, 0, batchSize)
-	count := 0
+	batchSize := e.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	batch := make([][]interface{}, 0, batchSize)
+
+	var governor *memoryGovernor
+	if e.MemoryLimit > 0 {
+		governor = newMemoryGovernor(e.MemoryLimit, batchSize)
+	}
 
+	interrupted := false
 	for rows.Next() {
-		if err := rows.Scan(valuePtrs...); err != nil {
-			return fmt.Errorf("error scanning row: %w", err)
+		if ctx.Err() != nil {
+			interrupted = true
+			break
 		}
 
-		// Convert values to strings
-		record := make([]string, len(e.columns))
-		for i, val := range values {
-			record[i] = formatValue(val)
+		if err := scanRow(rows, valuePtrs); err != nil {
+			if ctx.Err() != nil {
+				interrupted = true
+				break
+			}
+			if !e.SkipBadRows {
+				return fmt.Errorf("error scanning row: %w", err)
+			}
+			e.stats.SkippedRows++
+			log.Printf("Warning: skipping unreadable row in table %s: %v", e.tableName, err)
+			continue
+		}
+		if e.progress != nil {
+			e.progress()
 		}
 
-		batch = append(batch, record)
-		count++
+		row := make([]interface{}, len(values))
+		copy(row, values)
+		for _, i := range jsonIdx {
+			compacted, err := compactJSON(row[i])
+			if err != nil {
+				return fmt.Errorf("invalid JSON in column %s: %w", e.columns[i], err)
+			}
+			row[i] = compacted
+		}
+		if external != nil {
+			if err := external.apply(row); err != nil {
+				return fmt.Errorf("error externalizing text in table %s: %w", e.tableName, err)
+			}
+		}
+		for i, column := range e.columns {
+			if row[i] == nil {
+				e.stats.NullCounts[column]++
+			}
+		}
+		if e.RowHash {
+			row = append(row, rowHash(row))
+		}
+		batch = append(batch, row)
+		e.stats.Rows++
 
-		if count >= batchSize {
-			if err := writer.WriteAll(batch); err != nil {
+		if len(batch) >= batchSize {
+			if err := writer.WriteBatch(batch); err != nil {
 				return fmt.Errorf("error writing batch: %w", err)
 			}
 			batch = batch[:0]
-			count = 0
+			if e.OnProgress != nil {
+				e.OnProgress(e.stats.Rows)
+			}
+			if governor != nil {
+				batchSize = governor.next(ctx)
+			}
 		}
 	}
 
+	if err := rows.Err(); err != nil {
+		if ctx.Err() == nil {
+			return fmt.Errorf("error reading rows: %w", err)
+		}
+		interrupted = true
+	}
+
 	// Write remaining records
 	if len(batch) > 0 {
-		if err := writer.WriteAll(batch); err != nil {
+		if err := writer.WriteBatch(batch); err != nil {
 			return fmt.Errorf("error writing final batch: %w", err)
 		}
 	}
 
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("error flushing output: %w", err)
+	}
+	if e.OnProgress != nil {
+		e.OnProgress(e.stats.Rows)
+	}
+
+	if interrupted {
+		return fmt.Errorf("export interrupted after %d rows: %w", e.stats.Rows, context.Cause(ctx))
+	}
+
 	return nil
 }
 
-// formatValue converts an interface{} to a string representation
-func formatValue(v interface{}) string {
-	if v == nil {
+// newRowWriter returns the writer for the configured output format
+func (e *TableExporter) newRowWriter(w io.Writer) (RowWriter, error) {
+	if e.ColumnComments != nil && e.format() != CSV {
+		return nil, fmt.Errorf("a comment header row requires CSV output")
+	}
+	if e.BoolFormat != "" && e.format() != CSV {
+		return nil, fmt.Errorf("a boolean format requires CSV output")
+	}
+	if e.TimeLayout != "" && e.format() != CSV {
+		return nil, fmt.Errorf("a time layout requires CSV output")
+	}
+	if e.BinaryEncoding != "" && e.BinaryEncoding != BinaryRaw && e.format() != CSV {
+		return nil, fmt.Errorf("a binary encoding requires CSV output")
+	}
+	if e.RepeatHeader > 0 && e.format() != CSV {
+		return nil, fmt.Errorf("repeated header rows require CSV output")
+	}
+	if e.MaxBytesPerFile > 0 && (e.format() == XLSX || e.RepeatHeader > 0) {
+		return nil, fmt.Errorf("splitting by size isn't supported for xlsx output or with repeated header rows")
+	}
+	if e.split() && e.IncrementalColumn != "" {
+		return nil, fmt.Errorf("an incremental export can't be split into files")
+	}
+	if e.Compress && e.format() == XLSX {
+		return nil, fmt.Errorf("xlsx output is already compressed and can't be gzipped")
+	}
+
+	switch e.format() {
+	case CSV:
+		delimiter, err := e.delimiter()
+		if err != nil {
+			return nil, err
+		}
+		writer := csv.NewWriter(w)
+		writer.Comma = delimiter
+		return &csvBatchWriter{
+			writer:      writer,
+			nullString:  e.NullString,
+			columnNulls: e.ColumnNullStrings,
+			skipHeader:  e.appending,
+			comments:    e.ColumnComments,
+			boolFormat:  e.BoolFormat,
+			binary:      e.BinaryEncoding,
+			timeLayout:  cmp.Or(e.TimeLayout, DefaultTimeLayout),
+			repeat:      e.RepeatHeader,
+		}, nil
+	case SQL:
+		return newSQLBatchWriter(w, e.Dialect, e.tableName), nil
+	case JSON:
+		return newJSONBatchWriter(w, false, e.PrettyJSON), nil
+	case JSONL:
+		return newJSONBatchWriter(w, true, false), nil
+	case XLSX:
+		return newXLSXBatchWriter(w, e.tableName), nil
+	default:
+		return nil, fmt.Errorf("unsupported output format: %s", e.Format)
+	}
+}
+
+// csvBatchWriter writes rows as CSV records
+type csvBatchWriter struct {
+	writer      *csv.Writer
+	nullString  string
+	columnNulls map[string]string
+	nulls       []string // NULL token of each column
+	skipHeader  bool     // appending to a file that already has one
+	comments    map[string]string
+	boolFormat  BoolFormat
+	bools       []bool // whether each column has a boolean type
+	binary      BinaryEncoding
+	binaries    []bool // whether each column has a binary type
+	timeLayout  string
+
+	// The header rows are written again every repeat data rows
+	repeat  int
+	header  [][]string
+	written int
+}
+
+func (c *csvBatchWriter) WriteHeader(columns []string, types []*sql.ColumnType) error {
+	c.nulls = make([]string, len(columns))
+	c.bools = make([]bool, len(columns))
+	c.binaries = make([]bool, len(columns))
+	for i, column := range columns {
+		c.nulls[i] = c.nullString
+		if token, ok := c.columnNulls[column]; ok {
+			c.nulls[i] = token
+		}
+		if i < len(types) && types[i] != nil {
+			c.bools[i] = isBoolType(types[i].DatabaseTypeName())
+			c.binaries[i] = isBinaryType(types[i].DatabaseTypeName())
+		}
+	}
+
+	c.header = [][]string{columns}
+	if c.comments != nil {
+		comments := make([]string, len(columns))
+		for i, column := range columns {
+			comments[i] = c.comments[column]
+		}
+		c.header = append(c.header, comments)
+	}
+
+	if c.skipHeader {
+		return nil
+	}
+	return c.writer.WriteAll(c.header)
+}
+
+func (c *csvBatchWriter) WriteBatch(rows [][]interface{}) error {
+	records := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		if c.repeat > 0 && c.written > 0 && c.written%c.repeat == 0 {
+			records = append(records, c.header...)
+		}
+		c.written++
+
+		// Convert values to strings
+		record := make([]string, len(row))
+		for j, val := range row {
+			if c.boolFormat != "" {
+				if s, ok := c.boolFormat.format(val, c.bools[j]); ok {
+					record[j] = s
+					continue
+				}
+			}
+			if s, ok := c.binary.encode(val, c.binaries[j]); ok {
+				record[j] = s
+				continue
+			}
+			record[j] = formatValue(val, c.nulls[j], c.timeLayout)
+		}
+		records = append(records, record)
+	}
+	return c.writer.WriteAll(records)
+}
+
+func (c *csvBatchWriter) Close() error {
+	return c.flush()
+}
+
+func (c *csvBatchWriter) flush() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// filterColumns adds the joined columns and applies IncludeColumns and
+// ExcludeColumns to the other exported
+// columns. Names are matched case-insensitively.
+func (e *TableExporter) filterColumns() error {
+	if err := e.addJoinColumns(); err != nil {
+		return err
+	}
+	if len(e.IncludeColumns) == 0 && len(e.ExcludeColumns) == 0 {
+		return nil
+	}
+
+	included := make(map[string]bool, len(e.IncludeColumns))
+	for _, name := range e.IncludeColumns {
+		found := false
+		for _, column := range e.columns {
+			if strings.EqualFold(column, name) {
+				included[column] = true
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("included column %s not found in table %s", name, e.tableName)
+		}
+	}
+
+	var columns []string
+	for _, column := range e.columns {
+		if _, ok := e.joinExprs[column]; ok {
+			columns = append(columns, column)
+			continue
+		}
+		if len(included) > 0 && !included[column] {
+			continue
+		}
+		excluded := false
+		for _, name := range e.ExcludeColumns {
+			if strings.EqualFold(column, name) {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			columns = append(columns, column)
+		}
+	}
+
+	if len(columns) == 0 {
+		return fmt.Errorf("no columns left to export in table %s", e.tableName)
+	}
+	e.columns = columns
+	return nil
+}
+
+// headerColumns returns the names of the columns written to the output
+func (e *TableExporter) headerColumns() []string {
+	if !e.RowHash {
+		return e.columns
+	}
+	return append(append([]string(nil), e.columns...), RowHashColumn)
+}
+
+// rowHash returns the hex SHA-256 of a row's values. Each value is length
+// prefixed and NULL is told apart from the empty string, so different rows
+// can't encode to the same input.
+func rowHash(row []interface{}) string {
+	h := sha256.New()
+	for _, val := range row {
+		if val == nil {
+			h.Write([]byte("-1:"))
+			continue
+		}
+		s := formatValue(val, "", "")
+		fmt.Fprintf(h, "%d:%s", len(s), s)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// nullString returns the text written for NULL values of a column
+func (e *TableExporter) nullString(column string) string {
+	if token, ok := e.ColumnNullStrings[column]; ok {
+		return token
+	}
+	return e.NullString
+}
+
+// jsonColumnIndexes returns the positions of JSONColumns in the export
+func (e *TableExporter) jsonColumnIndexes() []int {
+	var indexes []int
+	for i, column := range e.columns {
+		for _, jsonColumn := range e.JSONColumns {
+			if column == jsonColumn {
+				indexes = append(indexes, i)
+				break
+			}
+		}
+	}
+	return indexes
+}
+
+// compactJSON validates a JSON value and removes insignificant whitespace
+func compactJSON(v interface{}) (interface{}, error) {
+	var raw []byte
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return v, nil
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// quoteIdentifier quotes a table or column name for the source database,
+// if its type is known
+func (e *TableExporter) quoteIdentifier(name string) string {
+	if e.DBType == "" {
+		return name
+	}
+	return database.QuoteIdentifier(e.DBType, name)
+}
+
+// selectList returns the column expressions of the export query
+func (e *TableExporter) selectList() string {
+	list := make([]string, len(e.columns))
+	for i, column := range e.columns {
+		if expr, ok := e.joinExprs[column]; ok {
+			list[i] = expr
+		} else if expr, ok := e.Expressions[column]; ok {
+			list[i] = expr
+		} else {
+			list[i] = e.columnRef(column)
+		}
+	}
+	return strings.Join(list, ", ")
+}
+
+// Query returns the SELECT statement the export runs
+func (e *TableExporter) Query() (string, error) {
+	if err := e.filterColumns(); err != nil {
+		return "", err
+	}
+	return e.buildQuery()
+}
+
+// whereClause returns the WHERE clause for Where, the incremental
+// watermark and random sampling, with a leading space
+func (e *TableExporter) whereClause() (string, error) {
+	var conditions []string
+	if e.Where != "" {
+		conditions = append(conditions, e.Where)
+	}
+	if e.hasWatermark {
+		literalType := e.DBType
+		if literalType == "" {
+			literalType = e.Dialect
+		}
+		conditions = append(conditions, fmt.Sprintf("%s > %s",
+			e.columnRef(e.IncrementalColumn), database.QuoteLiteral(literalType, e.watermark)))
+	}
+	if e.TableSample > 0 && !e.pageSample() {
+		sample, err := database.RandomSample(e.DBType, e.TableSample)
+		if err != nil {
+			return "", fmt.Errorf("error sampling table %s: %w", e.tableName, err)
+		}
+		conditions = append(conditions, sample)
+	}
+
+	switch len(conditions) {
+	case 0:
+		return "", nil
+	case 1:
+		return " WHERE " + conditions[0], nil
+	default:
+		if e.Where != "" {
+			conditions[0] = "(" + e.Where + ")"
+		}
+		return " WHERE " + strings.Join(conditions, " AND "), nil
+	}
+}
+
+// orderByClause returns the ORDER BY clause for OrderBy or the primary key,
+// or of incremental exports for their watermark column, with a leading space
+func (e *TableExporter) orderByClause() string {
+	switch {
+	case e.IncrementalColumn != "":
+		return " ORDER BY " + e.columnRef(e.IncrementalColumn)
+	case e.OrderBy != "":
+		return " ORDER BY " + e.OrderBy
+	case e.OrderByPrimaryKey && len(e.PrimaryKey) > 0:
+		keys := make([]string, len(e.PrimaryKey))
+		for i, key := range e.PrimaryKey {
+			keys[i] = e.columnRef(key)
+		}
+		return " ORDER BY " + strings.Join(keys, ", ")
+	}
+	return ""
+}
+
+// limitClause returns the LIMIT clause for Limit, with a leading space
+func (e *TableExporter) limitClause() string {
+	if e.Limit <= 0 {
 		return ""
 	}
+	return fmt.Sprintf(" LIMIT %d", e.Limit)
+}
+
+// buildQuery returns the SELECT statement used to read the table
+func (e *TableExporter) buildQuery() (string, error) {
+	if e.TableSample > 100 {
+		return "", fmt.Errorf("sample percentage must be at most 100, got %v", e.TableSample)
+	}
+	if e.OrderBy != "" && e.IncrementalColumn != "" {
+		return "", fmt.Errorf("an incremental export is ordered by its column and can't have an order")
+	}
+	where, err := e.whereClause()
+	if err != nil {
+		return "", err
+	}
+
+	if e.QueryTemplate == "" {
+		return fmt.Sprintf("SELECT %s FROM %s%s%s%s",
+			e.selectList(),
+			e.fromClause(),
+			where,
+			e.orderByClause(),
+			e.limitClause()), nil
+	}
+
+	if !strings.Contains(e.QueryTemplate, "{table}") {
+		return "", fmt.Errorf("query template must contain {table}")
+	}
+	if where != "" && !strings.Contains(e.QueryTemplate, "{where}") {
+		return "", fmt.Errorf("query template must contain {where} for -where, incremental exports and sampling")
+	}
+
+	// Unquoted identifiers are substituted verbatim, so make sure none of
+	// them can break out of the template
+	if e.DBType == "" {
+		idents := append([]string{e.tableName}, e.columns...)
+		if e.OrderByPrimaryKey {
+			idents = append(idents, e.PrimaryKey...)
+		}
+		for _, join := range e.Joins {
+			idents = append(append(idents, join.Table, join.Column, join.JoinColumn), join.Columns...)
+		}
+		for _, ident := range idents {
+			if !identifierPattern.MatchString(ident) {
+				return "", fmt.Errorf("invalid identifier in query template: %q", ident)
+			}
+		}
+	}
+
+	// Templates without an {order} or {limit} placeholder get the order
+	// and limit appended, which only works if the order goes first
+	template := e.QueryTemplate
+	if (e.OrderBy != "" || e.IncrementalColumn != "") && !strings.Contains(template, "{order}") {
+		if strings.Contains(template, "{limit}") {
+			return "", fmt.Errorf("query template with {limit} must also contain {order} to be ordered")
+		}
+		template += "{order}"
+	}
+	if !strings.Contains(template, "{limit}") {
+		template += "{limit}"
+	}
+
+	replacer := strings.NewReplacer(
+		"{columns}", e.selectList(),
+		"{table}", e.fromClause(),
+		"{where}", where,
+		"{order}", e.orderByClause(),
+		"{limit}", e.limitClause(),
+	)
+	return replacer.Replace(template), nil
+}
+
+// formatValue converts an interface{} to a string representation, using
+// nullString for NULL values. Times are formatted with timeLayout; an empty
+// timeLayout keeps Go's default format, so row hashes and external text
+// file names stay as they were. The sql.Null* wrappers are unwrapped, so
+// their values format like the plain ones and invalid ones as NULL.
+func formatValue(v interface{}, nullString, timeLayout string) string {
+	switch t := v.(type) {
+	case *time.Time:
+		if t == nil {
+			return nullString
+		}
+		v = *t
+	case sql.NullTime:
+		if !t.Valid {
+			return nullString
+		}
+		v = t.Time
+	case sql.RawBytes:
+		// A nil RawBytes is a NULL scanned into it
+		if t == nil {
+			return nullString
+		}
+		v = []byte(t)
+	case sql.NullString:
+		if !t.Valid {
+			return nullString
+		}
+		v = t.String
+	case sql.NullInt64:
+		if !t.Valid {
+			return nullString
+		}
+		v = t.Int64
+	case sql.NullInt32:
+		if !t.Valid {
+			return nullString
+		}
+		v = t.Int32
+	case sql.NullFloat64:
+		if !t.Valid {
+			return nullString
+		}
+		v = t.Float64
+	case sql.NullBool:
+		if !t.Valid {
+			return nullString
+		}
+		v = t.Bool
+	}
+
+	if v == nil {
+		return nullString
+	}
 	switch v := v.(type) {
 	case []byte:
 		return string(v)
+	case time.Time:
+		if timeLayout == "" {
+			return v.String()
+		}
+		return v.Format(timeLayout)
 	default:
 		return fmt.Sprintf("%v", v)
 	}
-} 
\ No newline at end of file
+}
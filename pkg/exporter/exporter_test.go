@@ -1,11 +1,20 @@
 package exporter
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"database/sql"
 	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sql2csv/pkg/database"
+	"strings"
 	"testing"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -112,16 +121,31 @@ func TestTableExporter_Export(t *testing.T) {
 }
 
 func TestFormatValue(t *testing.T) {
+	testTime := time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC)
 	tests := []struct {
-		name  string
-		input interface{}
-		want  string
+		name       string
+		input      interface{}
+		nullString string
+		timeLayout string
+		want       string
 	}{
 		{
 			name:  "Nil value",
 			input: nil,
 			want:  "",
 		},
+		{
+			name:       "Nil value with placeholder",
+			input:      nil,
+			nullString: `\N`,
+			want:       `\N`,
+		},
+		{
+			name:       "Empty string with placeholder",
+			input:      "",
+			nullString: `\N`,
+			want:       "",
+		},
 		{
 			name:  "String value",
 			input: "test",
@@ -137,14 +161,1388 @@ func TestFormatValue(t *testing.T) {
 			input: []byte("test bytes"),
 			want:  "test bytes",
 		},
+		{
+			name:       "Time with a layout",
+			input:      testTime,
+			timeLayout: time.RFC3339,
+			want:       "2009-11-10T23:00:00Z",
+		},
+		{
+			name:       "Time with a custom layout",
+			input:      testTime,
+			timeLayout: "2006-01-02 15:04",
+			want:       "2009-11-10 23:00",
+		},
+		{
+			name:  "Time without a layout",
+			input: testTime,
+			want:  "2009-11-10 23:00:00 +0000 UTC",
+		},
+		{
+			name:       "Time pointer",
+			input:      &testTime,
+			timeLayout: time.RFC3339,
+			want:       "2009-11-10T23:00:00Z",
+		},
+		{
+			name:       "Nil time pointer",
+			input:      (*time.Time)(nil),
+			nullString: `\N`,
+			timeLayout: time.RFC3339,
+			want:       `\N`,
+		},
+		{
+			name:       "Valid NullTime",
+			input:      sql.NullTime{Time: testTime, Valid: true},
+			timeLayout: time.RFC3339,
+			want:       "2009-11-10T23:00:00Z",
+		},
+		{
+			name:       "NULL NullTime",
+			input:      sql.NullTime{},
+			nullString: `\N`,
+			timeLayout: time.RFC3339,
+			want:       `\N`,
+		},
+		{
+			name:  "RawBytes decimal",
+			input: sql.RawBytes("12345678901234567890.123456789"),
+			want:  "12345678901234567890.123456789",
+		},
+		{
+			name:       "Empty RawBytes",
+			input:      sql.RawBytes{},
+			nullString: `\N`,
+			want:       "",
+		},
+		{
+			name:       "NULL RawBytes",
+			input:      sql.RawBytes(nil),
+			nullString: `\N`,
+			want:       `\N`,
+		},
+		{
+			name:  "Valid NullString",
+			input: sql.NullString{String: "0.10", Valid: true},
+			want:  "0.10",
+		},
+		{
+			name:       "NULL NullString",
+			input:      sql.NullString{},
+			nullString: `\N`,
+			want:       `\N`,
+		},
+		{
+			name:  "Valid NullInt64",
+			input: sql.NullInt64{Int64: -42, Valid: true},
+			want:  "-42",
+		},
+		{
+			name:       "NULL NullInt64",
+			input:      sql.NullInt64{},
+			nullString: `\N`,
+			want:       `\N`,
+		},
+		{
+			name:  "Valid NullInt32",
+			input: sql.NullInt32{Int32: 7, Valid: true},
+			want:  "7",
+		},
+		{
+			name:       "NULL NullInt32",
+			input:      sql.NullInt32{},
+			nullString: `\N`,
+			want:       `\N`,
+		},
+		{
+			name:  "Valid NullFloat64",
+			input: sql.NullFloat64{Float64: 2.5, Valid: true},
+			want:  "2.5",
+		},
+		{
+			name:       "NULL NullFloat64",
+			input:      sql.NullFloat64{},
+			nullString: `\N`,
+			want:       `\N`,
+		},
+		{
+			name:  "Valid NullBool",
+			input: sql.NullBool{Bool: false, Valid: true},
+			want:  "false",
+		},
+		{
+			name:       "NULL NullBool",
+			input:      sql.NullBool{},
+			nullString: `\N`,
+			want:       `\N`,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := formatValue(tt.input)
+			got := formatValue(tt.input, tt.nullString, tt.timeLayout)
 			if got != tt.want {
 				t.Errorf("formatValue() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestTableExporter_BuildQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		table    string
+		columns  []string
+		template string
+		exprs    map[string]string
+		where    string
+		orderBy  string
+		key      []string // primary key to order by
+		column   string   // incremental column
+		limit    int
+		sample   float64
+		view     bool
+		dbType   database.DBType
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:    "Default query",
+			table:   "users",
+			columns: []string{"id", "name"},
+			want:    "SELECT id, name FROM users",
+		},
+		{
+			name:     "Template with hint",
+			table:    "users",
+			columns:  []string{"id", "name"},
+			template: "SELECT {columns} FROM {table} FORCE INDEX (PRIMARY){where}",
+			want:     "SELECT id, name FROM users FORCE INDEX (PRIMARY)",
+		},
+		{
+			name:    "Where clause",
+			table:   "users",
+			columns: []string{"id", "name"},
+			where:   "status = 'active'",
+			want:    "SELECT id, name FROM users WHERE status = 'active'",
+		},
+		{
+			name:     "Template with where clause",
+			table:    "users",
+			columns:  []string{"id"},
+			template: "SELECT {columns} FROM {table} FORCE INDEX (PRIMARY){where} LIMIT 10",
+			where:    "id > 5",
+			want:     "SELECT id FROM users FORCE INDEX (PRIMARY) WHERE id > 5 LIMIT 10",
+		},
+		{
+			name:    "Limit",
+			table:   "users",
+			columns: []string{"id"},
+			where:   "id > 5",
+			limit:   10,
+			want:    "SELECT id FROM users WHERE id > 5 LIMIT 10",
+		},
+		{
+			name:    "Order between where and limit",
+			table:   "users",
+			columns: []string{"id"},
+			where:   "id > 5",
+			orderBy: "created_at DESC, id",
+			limit:   10,
+			want:    "SELECT id FROM users WHERE id > 5 ORDER BY created_at DESC, id LIMIT 10",
+		},
+		{
+			name:     "Template with order placeholder",
+			table:    "users",
+			columns:  []string{"id"},
+			template: "SELECT {columns} FROM {table}{where}{order}{limit} -- sorted",
+			orderBy:  "id",
+			limit:    5,
+			want:     "SELECT id FROM users ORDER BY id LIMIT 5 -- sorted",
+		},
+		{
+			name:     "Template without order placeholder",
+			table:    "users",
+			columns:  []string{"id"},
+			template: "SELECT {columns} FROM {table}{where}",
+			orderBy:  "id",
+			limit:    5,
+			want:     "SELECT id FROM users ORDER BY id LIMIT 5",
+		},
+		{
+			name:     "Template with limit but no order placeholder",
+			table:    "users",
+			columns:  []string{"id"},
+			template: "SELECT {columns} FROM {table}{limit}",
+			orderBy:  "id",
+			wantErr:  true,
+		},
+		{
+			name:     "Template without where placeholder",
+			table:    "users",
+			columns:  []string{"id"},
+			template: "SELECT {columns} FROM {table} FORCE INDEX (PRIMARY)",
+			where:    "id > 5",
+			wantErr:  true,
+		},
+		{
+			name:     "Incremental template without order placeholder",
+			table:    "users",
+			columns:  []string{"id"},
+			template: "SELECT {columns} FROM {table}{where}",
+			column:   "id",
+			want:     "SELECT id FROM users ORDER BY id",
+		},
+		{
+			name:     "Sampled template without where placeholder",
+			table:    "users",
+			columns:  []string{"id"},
+			template: "SELECT {columns} FROM {table}",
+			sample:   10,
+			dbType:   database.SQLite,
+			wantErr:  true,
+		},
+		{
+			name:    "Primary key order",
+			table:   "order_lines",
+			columns: []string{"order_id", "line", "sku"},
+			key:     []string{"order_id", "line"},
+			limit:   10,
+			dbType:  database.MySQL,
+			want:    "SELECT `order_id`, `line`, `sku` FROM `order_lines` ORDER BY `order_id`, `line` LIMIT 10",
+		},
+		{
+			name:    "Explicit order over primary key",
+			table:   "users",
+			columns: []string{"id"},
+			orderBy: "name",
+			key:     []string{"id"},
+			want:    "SELECT id FROM users ORDER BY name",
+		},
+		{
+			name:     "Template ordered by primary key at placeholder only",
+			table:    "users",
+			columns:  []string{"id"},
+			template: "SELECT {columns} FROM {table} ORDER BY id DESC",
+			key:      []string{"id"},
+			want:     "SELECT id FROM users ORDER BY id DESC",
+		},
+		{
+			name:    "Negative limit",
+			table:   "users",
+			columns: []string{"id"},
+			limit:   -1,
+			want:    "SELECT id FROM users",
+		},
+		{
+			name:     "Template with limit placeholder",
+			table:    "users",
+			columns:  []string{"id"},
+			template: "SELECT {columns} FROM {table}{limit} -- preview",
+			limit:    5,
+			want:     "SELECT id FROM users LIMIT 5 -- preview",
+		},
+		{
+			name:     "Template without limit placeholder",
+			table:    "users",
+			columns:  []string{"id"},
+			template: "SELECT {columns} FROM {table} ORDER BY id",
+			limit:    5,
+			want:     "SELECT id FROM users ORDER BY id LIMIT 5",
+		},
+		{
+			name:    "Column expression",
+			table:   "places",
+			columns: []string{"id", "location"},
+			exprs:   map[string]string{"location": "ST_AsText(`location`) AS `location`"},
+			want:    "SELECT id, ST_AsText(`location`) AS `location` FROM places",
+		},
+		{
+			name:     "Template without table placeholder",
+			table:    "users",
+			columns:  []string{"id"},
+			template: "SELECT {columns} FROM users",
+			wantErr:  true,
+		},
+		{
+			name:     "Unsafe identifier",
+			table:    "users; DROP TABLE users",
+			columns:  []string{"id"},
+			template: "SELECT {columns} FROM {table}",
+			wantErr:  true,
+		},
+		{
+			name:    "Quoted for MySQL",
+			table:   "group",
+			columns: []string{"id", "from"},
+			dbType:  database.MySQL,
+			want:    "SELECT `id`, `from` FROM `group`",
+		},
+		{
+			name:     "Quoted template",
+			table:    "order lines",
+			columns:  []string{"id"},
+			template: "SELECT {columns} FROM {table}{where}",
+			where:    "id > 5",
+			dbType:   database.Postgres,
+			want:     `SELECT "id" FROM "order lines" WHERE id > 5`,
+		},
+		{
+			name:    "Postgres table sample",
+			table:   "events",
+			columns: []string{"id"},
+			where:   "id > 5",
+			sample:  0.5,
+			dbType:  database.Postgres,
+			want:    `SELECT "id" FROM "events" TABLESAMPLE SYSTEM (0.5) WHERE id > 5`,
+		},
+		{
+			name:    "Postgres view sample",
+			table:   "recent_events",
+			columns: []string{"id"},
+			sample:  0.5,
+			view:    true,
+			dbType:  database.Postgres,
+			want:    `SELECT "id" FROM "recent_events" WHERE random() < 0.005`,
+		},
+		{
+			name:    "Random sample elsewhere",
+			table:   "events",
+			columns: []string{"id"},
+			where:   "id > 5 OR id < 2",
+			sample:  10,
+			dbType:  database.MySQL,
+			want:    "SELECT `id` FROM `events` WHERE (id > 5 OR id < 2) AND RAND() < 0.1",
+		},
+		{
+			name:    "Sample above 100 percent",
+			table:   "events",
+			columns: []string{"id"},
+			sample:  150,
+			dbType:  database.Postgres,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp := NewTableExporter(nil, tt.table, tt.columns, ".")
+			exp.QueryTemplate = tt.template
+			exp.Expressions = tt.exprs
+			exp.Where = tt.where
+			exp.OrderBy = tt.orderBy
+			exp.PrimaryKey = tt.key
+			exp.OrderByPrimaryKey = tt.key != nil
+			exp.IncrementalColumn = tt.column
+			exp.Limit = tt.limit
+			exp.TableSample = tt.sample
+			exp.View = tt.view
+			exp.DBType = tt.dbType
+			got, err := exp.buildQuery()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("buildQuery() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("buildQuery() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTableExporter_SkipBadRows(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE test_table (id INTEGER PRIMARY KEY, name TEXT);
+		INSERT INTO test_table (name) VALUES ('a'), ('b'), ('c');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	outputDir, err := os.MkdirTemp("", "csv_output")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	// Fail the scan of the second row
+	originalScanRow := scanRow
+	defer func() { scanRow = originalScanRow }()
+	calls := 0
+	scanRow = func(rows *sql.Rows, dest []interface{}) error {
+		calls++
+		if calls == 2 {
+			return errors.New("corrupt row")
+		}
+		return originalScanRow(rows, dest)
+	}
+
+	exp := NewTableExporter(db, "test_table", []string{"id", "name"}, outputDir)
+	if err := exp.Export(); err == nil {
+		t.Fatal("Export() expected error without SkipBadRows, got nil")
+	}
+
+	calls = 0
+	exp.SkipBadRows = true
+	if err := exp.Export(); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	stats := exp.Stats()
+	if stats.Rows != 2 || stats.SkippedRows != 1 || stats.Elapsed <= 0 {
+		t.Errorf("Stats() = %+v, want 2 rows, 1 skipped and the time taken", stats)
+	}
+
+	file, err := os.Open(exp.OutputPath())
+	if err != nil {
+		t.Fatalf("Failed to open output file: %v", err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV file: %v", err)
+	}
+	if len(records) != 3 || records[1][1] != "a" || records[2][1] != "c" {
+		t.Errorf("CSV records = %v, want header plus rows a and c", records)
+	}
+}
+
+func TestTableExporter_NullCounts(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE test_table (id INTEGER PRIMARY KEY, name TEXT, email TEXT);
+		INSERT INTO test_table (name, email) VALUES
+		('a', NULL), (NULL, NULL), ('c', 'c@example.com');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	outputDir, err := os.MkdirTemp("", "csv_output")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	exp := NewTableExporter(db, "test_table", []string{"id", "name", "email"}, outputDir)
+	if err := exp.Export(); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	want := map[string]int64{"id": 0, "name": 1, "email": 2}
+	got := exp.Stats().NullCounts
+	for column, count := range want {
+		if got[column] != count {
+			t.Errorf("NullCounts[%s] = %d, want %d", column, got[column], count)
+		}
+	}
+}
+
+func TestTableExporter_Delimiter(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE test_table (id INTEGER PRIMARY KEY, name TEXT);
+		INSERT INTO test_table (name) VALUES ('Doe, John');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	outputDir, err := os.MkdirTemp("", "csv_output")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	tests := []struct {
+		name      string
+		delimiter rune
+		wantFile  string
+		wantErr   bool
+	}{
+		{name: "Default comma", delimiter: 0, wantFile: "test_table.csv"},
+		{name: "Semicolon", delimiter: ';', wantFile: "test_table.csv"},
+		{name: "Tab", delimiter: '\t', wantFile: "test_table.tsv"},
+		{name: "Newline", delimiter: '\n', wantErr: true},
+		{name: "Carriage return", delimiter: '\r', wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp := NewTableExporter(db, "test_table", []string{"id", "name"}, outputDir)
+			exp.Delimiter = tt.delimiter
+			err := exp.Export()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Export() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if filepath.Base(exp.OutputPath()) != tt.wantFile {
+				t.Errorf("OutputPath() = %s, want %s", exp.OutputPath(), tt.wantFile)
+			}
+
+			file, err := os.Open(exp.OutputPath())
+			if err != nil {
+				t.Fatalf("Failed to open output file: %v", err)
+			}
+			defer file.Close()
+
+			reader := csv.NewReader(file)
+			if tt.delimiter != 0 {
+				reader.Comma = tt.delimiter
+			}
+			records, err := reader.ReadAll()
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+			if len(records) != 2 || records[1][1] != "Doe, John" {
+				t.Errorf("Records = %v, want header and one row", records)
+			}
+		})
+	}
+}
+
+func TestTableExporter_ExportContextCancelled(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE test_table (id INTEGER PRIMARY KEY, name TEXT);
+		WITH RECURSIVE seq(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM seq WHERE n < 2500)
+		INSERT INTO test_table (name) SELECT 'row' || n FROM seq;
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	outputDir, err := os.MkdirTemp("", "csv_output")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	// Run out of time part way through the second batch
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	originalScanRow := scanRow
+	defer func() { scanRow = originalScanRow }()
+	calls := 0
+	scanRow = func(rows *sql.Rows, dest []interface{}) error {
+		calls++
+		if calls == 1200 {
+			cancel(context.DeadlineExceeded)
+		}
+		return originalScanRow(rows, dest)
+	}
+
+	exp := NewTableExporter(db, "test_table", []string{"id", "name"}, outputDir)
+	err = exp.ExportContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ExportContext() error = %v, want context.DeadlineExceeded", err)
+	}
+
+	stats := exp.Stats()
+	if stats.Rows < DefaultBatchSize || stats.Rows > 1200 {
+		t.Errorf("Stats().Rows = %d, want between %d and 1200", stats.Rows, DefaultBatchSize)
+	}
+
+	// The rows read before the cancellation must be flushed to a valid file
+	file, err := os.Open(exp.OutputPath())
+	if err != nil {
+		t.Fatalf("Failed to open output file: %v", err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV file: %v", err)
+	}
+	if int64(len(records)) != stats.Rows+1 {
+		t.Errorf("CSV has %d records, want header plus %d rows", len(records), stats.Rows)
+	}
+}
+
+func TestTableExporter_ExportContextInterrupted(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE test_table (id INTEGER PRIMARY KEY, name TEXT);
+		WITH RECURSIVE seq(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM seq WHERE n < 2500)
+		INSERT INTO test_table (name) SELECT 'row' || n FROM seq;
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	outputDir, err := os.MkdirTemp("", "csv_output")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	// The export of an earlier run must survive the interrupted one
+	exp := NewTableExporter(db, "test_table", []string{"id", "name"}, outputDir)
+	previous := "id,name\n1,earlier\n"
+	if err := os.WriteFile(exp.OutputPath(), []byte(previous), 0644); err != nil {
+		t.Fatalf("Failed to write previous export: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	originalScanRow := scanRow
+	defer func() { scanRow = originalScanRow }()
+	calls := 0
+	scanRow = func(rows *sql.Rows, dest []interface{}) error {
+		calls++
+		if calls == 1200 {
+			cancel()
+		}
+		return originalScanRow(rows, dest)
+	}
+
+	err = exp.ExportContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ExportContext() error = %v, want context.Canceled", err)
+	}
+
+	content, err := os.ReadFile(exp.OutputPath())
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	if string(content) != previous {
+		t.Errorf("Output file = %q, want the previous export %q", content, previous)
+	}
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		t.Fatalf("Failed to read output directory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Output directory has %d entries, want only the previous export", len(entries))
+	}
+}
+
+func TestTableExporter_Compress(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE test_table (id INTEGER PRIMARY KEY, name TEXT);
+		INSERT INTO test_table (name) VALUES ('a'), ('b');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	outputDir, err := os.MkdirTemp("", "csv_output")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	exp := NewTableExporter(db, "test_table", []string{"id", "name"}, outputDir)
+	exp.Compress = true
+	if err := exp.Export(); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	if filepath.Base(exp.OutputPath()) != "test_table.csv.gz" {
+		t.Errorf("OutputPath() = %s, want test_table.csv.gz", exp.OutputPath())
+	}
+
+	file, err := os.Open(exp.OutputPath())
+	if err != nil {
+		t.Fatalf("Failed to open output file: %v", err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("Failed to open gzip stream: %v", err)
+	}
+	defer gz.Close()
+
+	records, err := csv.NewReader(gz).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV file: %v", err)
+	}
+	if len(records) != 3 || records[0][1] != "name" || records[2][1] != "b" {
+		t.Errorf("CSV records = %v, want header plus rows a and b", records)
+	}
+}
+
+func TestTableExporter_JSONColumns(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE test_table (id INTEGER PRIMARY KEY, doc JSON);
+		INSERT INTO test_table (doc) VALUES ('{"a": 1, "b": [1, 2]}'), (NULL);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	outputDir, err := os.MkdirTemp("", "csv_output")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	exp := NewTableExporter(db, "test_table", []string{"id", "doc"}, outputDir)
+	exp.JSONColumns = []string{"doc"}
+	if err := exp.Export(); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	file, err := os.Open(exp.OutputPath())
+	if err != nil {
+		t.Fatalf("Failed to open output file: %v", err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV file: %v", err)
+	}
+	if len(records) != 3 || records[1][1] != `{"a":1,"b":[1,2]}` || records[2][1] != "" {
+		t.Errorf("CSV records = %v, want compact JSON and an empty NULL", records)
+	}
+
+	// Invalid JSON fails the export
+	if _, err := db.Exec(`INSERT INTO test_table (doc) VALUES ('{not json')`); err != nil {
+		t.Fatalf("Failed to insert row: %v", err)
+	}
+	if err := exp.Export(); err == nil {
+		t.Error("Export() expected error for invalid JSON, got nil")
+	}
+}
+
+func TestTableExporter_FilterColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "No filters", want: []string{"id", "Name", "email", "password"}},
+		{name: "Include", include: []string{"email", "id"}, want: []string{"id", "email"}},
+		{name: "Include case-insensitive", include: []string{"NAME"}, want: []string{"Name"}},
+		{name: "Exclude case-insensitive", exclude: []string{"PASSWORD"}, want: []string{"id", "Name", "email"}},
+		{name: "Include and exclude", include: []string{"id", "password"}, exclude: []string{"password"}, want: []string{"id"}},
+		{name: "Unknown include", include: []string{"phone"}, wantErr: true},
+		{name: "Everything excluded", include: []string{"id"}, exclude: []string{"id"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp := NewTableExporter(nil, "users", []string{"id", "Name", "email", "password"}, ".")
+			exp.IncludeColumns = tt.include
+			exp.ExcludeColumns = tt.exclude
+			err := exp.filterColumns()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("filterColumns() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if strings.Join(exp.columns, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("columns = %v, want %v", exp.columns, tt.want)
+			}
+		})
+	}
+}
+
+func TestTableExporter_ExportStream(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE test_table (id INTEGER PRIMARY KEY, name TEXT);
+		INSERT INTO test_table (name) VALUES ('a'), ('b');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	// No output directory is needed when streaming
+	exp := NewTableExporter(db, "test_table", []string{"id", "name"}, "")
+	var buf bytes.Buffer
+	if err := exp.ExportStream(context.Background(), &buf); err != nil {
+		t.Fatalf("ExportStream() error = %v", err)
+	}
+
+	if want := "id,name\n1,a\n2,b\n"; buf.String() != want {
+		t.Errorf("ExportStream() wrote %q, want %q", buf.String(), want)
+	}
+
+	// A compressed stream is a complete gzip stream of the same CSV
+	exp.Compress = true
+	buf.Reset()
+	if err := exp.ExportStream(context.Background(), &buf); err != nil {
+		t.Fatalf("ExportStream() error = %v", err)
+	}
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("Failed to open gzip stream: %v", err)
+	}
+	content, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("Failed to read gzip stream: %v", err)
+	}
+	if want := "id,name\n1,a\n2,b\n"; string(content) != want {
+		t.Errorf("Compressed ExportStream() wrote %q, want %q", content, want)
+	}
+}
+
+func TestTableExporter_ReadTimeout(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE test_table (id INTEGER PRIMARY KEY, name TEXT);
+		INSERT INTO test_table (name) VALUES ('a'), ('b'), ('c'), ('d'), ('e');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	outputDir, err := os.MkdirTemp("", "csv_output")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	originalScanRow := scanRow
+	defer func() { scanRow = originalScanRow }()
+
+	tests := []struct {
+		name    string
+		delay   func(call int) time.Duration
+		wantErr bool
+	}{
+		{
+			// 5 rows at 40ms each take longer than the timeout in total
+			name:  "Slow but progressing",
+			delay: func(call int) time.Duration { return 40 * time.Millisecond },
+		},
+		{
+			name: "Stalled row",
+			delay: func(call int) time.Duration {
+				if call == 3 {
+					return 500 * time.Millisecond
+				}
+				return 0
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			scanRow = func(rows *sql.Rows, dest []interface{}) error {
+				calls++
+				time.Sleep(tt.delay(calls))
+				return originalScanRow(rows, dest)
+			}
+
+			exp := NewTableExporter(db, "test_table", []string{"id", "name"}, outputDir)
+			exp.ReadTimeout = 100 * time.Millisecond
+			err := exp.Export()
+			if tt.wantErr {
+				if !errors.Is(err, ErrReadTimeout) {
+					t.Fatalf("Export() error = %v, want ErrReadTimeout", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Export() error = %v", err)
+			}
+			if exp.Stats().Rows != 5 {
+				t.Errorf("Stats().Rows = %d, want 5", exp.Stats().Rows)
+			}
+		})
+	}
+}
+
+func TestTableExporter_ColumnComments(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE test_table (id INTEGER PRIMARY KEY, name TEXT);
+		INSERT INTO test_table (name) VALUES ('a');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		format   Format
+		comments map[string]string
+		want     string
+		wantErr  bool
+	}{
+		{name: "No comment row", format: CSV, want: "id,name\n1,a\n"},
+		{
+			name:     "Comment row with blanks",
+			format:   CSV,
+			comments: map[string]string{"name": "Full name, as entered"},
+			want:     "id,name\n,\"Full name, as entered\"\n1,a\n",
+		},
+		{name: "Not CSV", format: JSON, comments: map[string]string{}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp := NewTableExporter(db, "test_table", []string{"id", "name"}, "")
+			exp.Format = tt.format
+			exp.ColumnComments = tt.comments
+			var buf bytes.Buffer
+			err := exp.ExportStream(context.Background(), &buf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExportStream() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && buf.String() != tt.want {
+				t.Errorf("ExportStream() wrote %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestTableExporter_ReservedWords(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE "group" (id INTEGER PRIMARY KEY, "from" TEXT, "first name" TEXT);
+		INSERT INTO "group" ("from", "first name") VALUES ('a', 'b');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	exp := NewTableExporter(db, "group", []string{"id", "from", "first name"}, "")
+	exp.DBType = database.SQLite
+	exp.IncrementalColumn = "from"
+	var buf bytes.Buffer
+	if err := exp.ExportStream(context.Background(), &buf); err != nil {
+		t.Fatalf("ExportStream() error = %v", err)
+	}
+
+	if want := "id,from,first name\n1,a,b\n"; buf.String() != want {
+		t.Errorf("ExportStream() wrote %q, want %q", buf.String(), want)
+	}
+}
+
+func TestTableExporter_RowHash(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// Rows 1 and 2 only differ in NULL vs empty string, rows 3 and 4 in
+	// where the values are split
+	_, err = db.Exec(`
+		CREATE TABLE test_table (a TEXT, b TEXT);
+		INSERT INTO test_table VALUES ('x', NULL), ('x', ''), ('ab', 'c'), ('a', 'bc'), ('x', NULL);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	exp := NewTableExporter(db, "test_table", []string{"a", "b"}, "")
+	exp.RowHash = true
+	var buf bytes.Buffer
+	if err := exp.ExportStream(context.Background(), &buf); err != nil {
+		t.Fatalf("ExportStream() error = %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %v", err)
+	}
+	if got := strings.Join(records[0], ","); got != "a,b,"+RowHashColumn {
+		t.Fatalf("header = %s, want a,b,%s", got, RowHashColumn)
+	}
+
+	hashes := make(map[string]int)
+	for _, record := range records[1:] {
+		if len(record[2]) != 64 {
+			t.Errorf("hash %q is not a hex SHA-256", record[2])
+		}
+		hashes[record[2]]++
+	}
+	// Only the identical first and last rows share a hash
+	if len(hashes) != 4 || hashes[records[1][2]] != 2 || records[1][2] != records[5][2] {
+		t.Errorf("row hashes = %v, want 4 distinct with rows 1 and 5 equal", hashes)
+	}
+	if exp.Stats().NullCounts["b"] != 2 {
+		t.Errorf("NullCounts[b] = %d, want 2", exp.Stats().NullCounts["b"])
+	}
+}
+
+func TestTableExporter_OnProgress(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE test_table (id INTEGER PRIMARY KEY);
+		WITH RECURSIVE seq(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM seq WHERE n < 2500)
+		INSERT INTO test_table (id) SELECT n FROM seq;
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	var calls []int64
+	exp := NewTableExporter(db, "test_table", []string{"id"}, "")
+	exp.OnProgress = func(rowsWritten int64) { calls = append(calls, rowsWritten) }
+	if err := exp.ExportStream(context.Background(), io.Discard); err != nil {
+		t.Fatalf("ExportStream() error = %v", err)
+	}
+
+	want := []int64{1000, 2000, 2500}
+	if fmt.Sprint(calls) != fmt.Sprint(want) {
+		t.Errorf("OnProgress calls = %v, want %v", calls, want)
+	}
+
+	// A custom batch size changes how often progress is reported
+	calls = nil
+	exp.BatchSize = 1200
+	if err := exp.ExportStream(context.Background(), io.Discard); err != nil {
+		t.Fatalf("ExportStream() error = %v", err)
+	}
+	want = []int64{1200, 2400, 2500}
+	if fmt.Sprint(calls) != fmt.Sprint(want) {
+		t.Errorf("OnProgress calls with BatchSize 1200 = %v, want %v", calls, want)
+	}
+}
+
+func TestTableExporter_ColumnNullStrings(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE test_table (name TEXT, age INTEGER, city TEXT);
+		INSERT INTO test_table VALUES (NULL, NULL, NULL), ('a', 1, 'b');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	exp := NewTableExporter(db, "test_table", []string{"name", "age", "city"}, "")
+	exp.NullString = `\N`
+	exp.ColumnNullStrings = map[string]string{"age": "0", "city": "N/A"}
+	var buf bytes.Buffer
+	if err := exp.ExportStream(context.Background(), &buf); err != nil {
+		t.Fatalf("ExportStream() error = %v", err)
+	}
+
+	if want := "name,age,city\n\\N,0,N/A\na,1,b\n"; buf.String() != want {
+		t.Errorf("ExportStream() wrote %q, want %q", buf.String(), want)
+	}
+}
+
+func BenchmarkTableExporter_BatchSize(b *testing.B) {
+	tmpfile, err := os.CreateTemp("", "bench.db")
+	if err != nil {
+		b.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		b.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE bench (id INTEGER PRIMARY KEY, name TEXT, score REAL);
+		WITH RECURSIVE seq(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM seq WHERE n < 20000)
+		INSERT INTO bench (id, name, score) SELECT n, 'name ' || n, n * 0.5 FROM seq;
+	`)
+	if err != nil {
+		b.Fatalf("Failed to create bench table: %v", err)
+	}
+
+	for _, size := range []int{10, 100, 1000, 10000} {
+		b.Run(fmt.Sprintf("batch=%d", size), func(b *testing.B) {
+			exp := NewTableExporter(db, "bench", []string{"id", "name", "score"}, "")
+			exp.BatchSize = size
+			for i := 0; i < b.N; i++ {
+				if err := exp.ExportStream(context.Background(), io.Discard); err != nil {
+					b.Fatalf("ExportStream() error = %v", err)
+				}
+			}
+			b.ReportMetric(float64(20000*b.N)/b.Elapsed().Seconds(), "rows/s")
+		})
+	}
+}
+
+func TestTableExporter_RepeatHeader(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE test_table (id INTEGER PRIMARY KEY, name TEXT);
+		INSERT INTO test_table (name) VALUES ('a'), ('b'), ('c'), ('d'), ('e');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		format    Format
+		repeat    int
+		batchSize int
+		comments  map[string]string
+		want      string
+		wantErr   bool
+	}{
+		{name: "Off", format: CSV, want: "id,name\n1,a\n2,b\n3,c\n4,d\n5,e\n"},
+		{name: "Every 2 rows", format: CSV, repeat: 2, want: "id,name\n1,a\n2,b\nid,name\n3,c\n4,d\nid,name\n5,e\n"},
+		{name: "Across batches", format: CSV, repeat: 2, batchSize: 3, want: "id,name\n1,a\n2,b\nid,name\n3,c\n4,d\nid,name\n5,e\n"},
+		{name: "No header after the last row", format: CSV, repeat: 5, want: "id,name\n1,a\n2,b\n3,c\n4,d\n5,e\n"},
+		{
+			name:     "With comment row",
+			format:   CSV,
+			repeat:   3,
+			comments: map[string]string{"name": "Name"},
+			want:     "id,name\n,Name\n1,a\n2,b\n3,c\nid,name\n,Name\n4,d\n5,e\n",
+		},
+		{name: "Not CSV", format: JSON, repeat: 2, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp := NewTableExporter(db, "test_table", []string{"id", "name"}, "")
+			exp.Format = tt.format
+			exp.RepeatHeader = tt.repeat
+			exp.BatchSize = tt.batchSize
+			exp.ColumnComments = tt.comments
+			var buf bytes.Buffer
+			err := exp.ExportStream(context.Background(), &buf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExportStream() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && buf.String() != tt.want {
+				t.Errorf("ExportStream() wrote %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestTableExporter_TimeLayout(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// The driver returns DATETIME columns as time.Time
+	_, err = db.Exec(`
+		CREATE TABLE events (id INTEGER PRIMARY KEY, at DATETIME);
+		INSERT INTO events (at) VALUES ('2009-11-10 23:00:00'), (NULL);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		format  Format
+		layout  string
+		want    string
+		wantErr bool
+	}{
+		{name: "Default layout", format: CSV, want: "id,at\n1,2009-11-10T23:00:00Z\n2,\n"},
+		{name: "Custom layout", format: CSV, layout: "02/01/2006", want: "id,at\n1,10/11/2009\n2,\n"},
+		{name: "Not CSV", format: JSON, layout: "02/01/2006", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp := NewTableExporter(db, "events", []string{"id", "at"}, "")
+			exp.Format = tt.format
+			exp.TimeLayout = tt.layout
+			var buf bytes.Buffer
+			err := exp.ExportStream(context.Background(), &buf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExportStream() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && buf.String() != tt.want {
+				t.Errorf("ExportStream() wrote %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
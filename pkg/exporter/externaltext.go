@@ -0,0 +1,125 @@
+package exporter
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ExternalTextDir is the directory, inside the output directory, that text
+// values over ExternalTextThreshold are written to
+const ExternalTextDir = "docs"
+
+// unsafeFileChars matches characters replaced in external text file names
+var unsafeFileChars = regexp.MustCompile(`[^\p{L}\p{N}._-]`)
+
+// textExternalizer moves oversized text values of a row into files and
+// replaces them with the files' relative paths
+type textExternalizer struct {
+	dir       string
+	table     string
+	columns   []string
+	threshold int
+	keys      []int  // positions of the primary key columns
+	text      []bool // whether a column may hold text
+	created   bool
+}
+
+// externalTextKeys returns the positions of the primary key columns, which
+// name the external text files
+func (e *TableExporter) externalTextKeys() ([]int, error) {
+	if len(e.PrimaryKey) == 0 {
+		return nil, fmt.Errorf("external text files require a primary key, but table %s has none", e.tableName)
+	}
+
+	var keys []int
+	for _, key := range e.PrimaryKey {
+		found := false
+		for i, column := range e.columns {
+			if column == key {
+				keys = append(keys, i)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("primary key column %s of table %s must be exported to name external text files",
+				key, e.tableName)
+		}
+	}
+	return keys, nil
+}
+
+func (e *TableExporter) newTextExternalizer(types []*sql.ColumnType) (*textExternalizer, error) {
+	keys, err := e.externalTextKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	text := make([]bool, len(e.columns))
+	for i := range text {
+		text[i] = i >= len(types) || !isBinaryType(types[i].DatabaseTypeName())
+	}
+
+	return &textExternalizer{
+		dir:       filepath.Join(e.outputDir, ExternalTextDir),
+		table:     e.tableName,
+		columns:   e.columns,
+		threshold: e.ExternalTextThreshold,
+		keys:      keys,
+		text:      text,
+	}, nil
+}
+
+// apply replaces the oversized text values of row in place
+func (x *textExternalizer) apply(row []interface{}) error {
+	var key string
+	for i, val := range row {
+		if !x.text[i] {
+			continue
+		}
+
+		var content []byte
+		switch v := val.(type) {
+		case string:
+			content = []byte(v)
+		case []byte:
+			content = v
+		default:
+			continue
+		}
+		if len(content) <= x.threshold || !utf8.Valid(content) {
+			continue
+		}
+
+		if key == "" {
+			parts := make([]string, len(x.keys))
+			for j, k := range x.keys {
+				if row[k] == nil {
+					return fmt.Errorf("NULL primary key in column %s", x.columns[k])
+				}
+				parts[j] = formatValue(row[k], "", "")
+			}
+			key = strings.Join(parts, "_")
+		}
+
+		if !x.created {
+			if err := os.MkdirAll(x.dir, 0755); err != nil {
+				return fmt.Errorf("error creating external text directory: %w", err)
+			}
+			x.created = true
+		}
+
+		name := unsafeFileChars.ReplaceAllString(fmt.Sprintf("%s_%s_%s", x.table, key, x.columns[i]), "_") + ".txt"
+		if err := os.WriteFile(filepath.Join(x.dir, name), content, 0644); err != nil {
+			return fmt.Errorf("error writing external text file: %w", err)
+		}
+		// Paths are relative to the export file and always use slashes
+		row[i] = ExternalTextDir + "/" + name
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package exporter
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestTableExporter_ExternalText(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	long := strings.Repeat("<p>page</p>", 10)
+	_, err = db.Exec(`
+		CREATE TABLE pages (id INTEGER PRIMARY KEY, title TEXT, body TEXT, raw BLOB);
+		INSERT INTO pages (title, body, raw) VALUES ('short', 'tiny', NULL), ('long', ?, ?);
+	`, long, []byte(long))
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		primaryKey []string
+		columns    []string
+		wantErr    bool
+	}{
+		{name: "Oversized text moved to a file", primaryKey: []string{"id"}, columns: []string{"id", "title", "body", "raw"}},
+		{name: "No primary key", columns: []string{"id", "title", "body", "raw"}, wantErr: true},
+		{name: "Primary key not exported", primaryKey: []string{"id"}, columns: []string{"title", "body"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputDir, err := os.MkdirTemp("", "csv_output")
+			if err != nil {
+				t.Fatalf("Failed to create temp directory: %v", err)
+			}
+			defer os.RemoveAll(outputDir)
+
+			exp := NewTableExporter(db, "pages", []string{"id", "title", "body", "raw"}, outputDir)
+			exp.IncludeColumns = tt.columns
+			exp.ExternalTextThreshold = 20
+			exp.PrimaryKey = tt.primaryKey
+			err = exp.Export()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Export() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			content, err := os.ReadFile(filepath.Join(outputDir, "pages.csv"))
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+			// Binary columns stay inline
+			want := "id,title,body,raw\n1,short,tiny,\n2,long,docs/pages_2_body.txt," + long + "\n"
+			if string(content) != want {
+				t.Errorf("CSV = %q, want %q", content, want)
+			}
+
+			doc, err := os.ReadFile(filepath.Join(outputDir, "docs", "pages_2_body.txt"))
+			if err != nil {
+				t.Fatalf("Failed to read external text file: %v", err)
+			}
+			if string(doc) != long {
+				t.Errorf("external text = %q, want %q", doc, long)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package exporter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CheckFileNameTemplate validates a file name template, see
+// TableExporter.FileNameTemplate. The empty template names files after the
+// table.
+func CheckFileNameTemplate(template string) error {
+	if template == "" {
+		return nil
+	}
+	if !strings.Contains(template, "{table}") {
+		return fmt.Errorf("file name template must contain {table}, so tables don't overwrite each other")
+	}
+	if strings.ContainsAny(template, `/\`) {
+		return fmt.Errorf("file name template must not contain a path separator; use the output directory instead")
+	}
+	return nil
+}
+
+// fileName returns the name, without extension, of the output file
+func (e *TableExporter) fileName() string {
+	if e.FileNameTemplate == "" {
+		return e.tableName
+	}
+	return strings.NewReplacer(
+		"{table}", e.tableName,
+		"{db}", e.DatabaseName,
+		"{date}", e.FileNameTime.Format("2006-01-02"),
+		"{time}", e.FileNameTime.Format("150405"),
+	).Replace(e.FileNameTemplate)
+}
@@ -0,0 +1,50 @@
+package exporter
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckFileNameTemplate(t *testing.T) {
+	for _, template := range []string{"", "{table}", "{db}_{table}_{date}", "export-{table}-{date}T{time}"} {
+		if err := CheckFileNameTemplate(template); err != nil {
+			t.Errorf("CheckFileNameTemplate(%q) error = %v", template, err)
+		}
+	}
+	for _, template := range []string{"{db}_{date}", "archive/{table}", `archive\{table}`} {
+		if err := CheckFileNameTemplate(template); err == nil {
+			t.Errorf("CheckFileNameTemplate(%q) error = nil, want an error", template)
+		}
+	}
+}
+
+func TestTableExporter_FileNameTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		compress bool
+		want     string
+		wantPart string
+	}{
+		{name: "No template", want: "users.csv", wantPart: "users_0002.csv"},
+		{name: "All placeholders", template: "{db}_{table}_{date}T{time}", want: "shop_users_2026-03-09T140507.csv", wantPart: "shop_users_2026-03-09T140507_0002.csv"},
+		{name: "Compressed", template: "{table}-{date}", compress: true, want: "users-2026-03-09.csv.gz", wantPart: "users-2026-03-09_0002.csv.gz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp := NewTableExporter(nil, "users", []string{"id"}, "out")
+			exp.FileNameTemplate = tt.template
+			exp.DatabaseName = "shop"
+			exp.FileNameTime = time.Date(2026, 3, 9, 14, 5, 7, 0, time.Local)
+			exp.Compress = tt.compress
+			if got := exp.OutputPath(); got != filepath.Join("out", tt.want) {
+				t.Errorf("OutputPath() = %s, want %s", got, filepath.Join("out", tt.want))
+			}
+			if got := exp.PartPath(2); got != filepath.Join("out", tt.wantPart) {
+				t.Errorf("PartPath(2) = %s, want %s", got, filepath.Join("out", tt.wantPart))
+			}
+		})
+	}
+}
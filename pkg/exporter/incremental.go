@@ -0,0 +1,91 @@
+package exporter
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// loadWatermark reads the existing output file of an incremental export.
+// A missing or empty file means a full export; otherwise the header must
+// match the exported columns and the last line holds the watermark.
+func (e *TableExporter) loadWatermark() error {
+	e.appending, e.watermark, e.hasWatermark = false, "", false
+
+	if e.format() != CSV || e.Compress {
+		return fmt.Errorf("incremental export requires uncompressed CSV output")
+	}
+	if e.ColumnComments != nil {
+		return fmt.Errorf("incremental export can't be combined with a comment header row")
+	}
+
+	column := -1
+	for i, name := range e.columns {
+		if name == e.IncrementalColumn {
+			column = i
+			break
+		}
+	}
+	if column < 0 {
+		return fmt.Errorf("incremental column %s not found in table %s", e.IncrementalColumn, e.tableName)
+	}
+
+	file, err := os.Open(e.OutputPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error opening existing output file: %w", err)
+	}
+	defer file.Close()
+
+	delimiter, err := e.delimiter()
+	if err != nil {
+		return err
+	}
+	reader := csv.NewReader(file)
+	reader.Comma = delimiter
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading existing output file: %w", err)
+	}
+	want := e.headerColumns()
+	if len(header) != len(want) {
+		return fmt.Errorf("existing output file has columns %v, want %v", header, want)
+	}
+	for i := range header {
+		if header[i] != want[i] {
+			return fmt.Errorf("existing output file has columns %v, want %v", header, want)
+		}
+	}
+	e.appending = true
+
+	// Records can span lines, so the file is read to the end rather than
+	// seeking to its last line
+	var last []string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading existing output file: %w", err)
+		}
+		last = record
+	}
+	if last == nil {
+		return nil
+	}
+
+	if last[column] == "" || last[column] == e.nullString(e.IncrementalColumn) {
+		return fmt.Errorf("last row of %s has no value for incremental column %s", e.OutputPath(), e.IncrementalColumn)
+	}
+	e.watermark, e.hasWatermark = last[column], true
+	return nil
+}
@@ -0,0 +1,103 @@
+package exporter
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"os"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestTableExporter_Incremental(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE events (id INTEGER PRIMARY KEY, name TEXT);
+		INSERT INTO events (id, name) VALUES (2, 'b'), (1, 'a'), (3, 'c');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	outputDir, err := os.MkdirTemp("", "csv_output")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	export := func() *TableExporter {
+		t.Helper()
+		exp := NewTableExporter(db, "events", []string{"id", "name"}, outputDir)
+		exp.IncrementalColumn = "id"
+		if err := exp.Export(); err != nil {
+			t.Fatalf("Export() error = %v", err)
+		}
+		return exp
+	}
+
+	// Without an existing file everything is exported in watermark order
+	if rows := export().Stats().Rows; rows != 3 {
+		t.Errorf("first export wrote %d rows, want 3", rows)
+	}
+
+	if _, err := db.Exec(`INSERT INTO events (id, name) VALUES (4, 'd'), (10, 'e')`); err != nil {
+		t.Fatalf("Failed to insert rows: %v", err)
+	}
+
+	// The second run only appends the new rows
+	exp := export()
+	if rows := exp.Stats().Rows; rows != 2 {
+		t.Errorf("second export wrote %d rows, want 2", rows)
+	}
+
+	// Nothing new: nothing appended
+	if rows := export().Stats().Rows; rows != 0 {
+		t.Errorf("third export wrote %d rows, want 0", rows)
+	}
+
+	file, err := os.Open(exp.OutputPath())
+	if err != nil {
+		t.Fatalf("Failed to open output file: %v", err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV file: %v", err)
+	}
+	want := []string{"id", "1", "2", "3", "4", "10"}
+	if len(records) != len(want) {
+		t.Fatalf("CSV records = %v, want ids %v", records, want)
+	}
+	for i, id := range want {
+		if records[i][0] != id {
+			t.Errorf("records[%d][0] = %s, want %s", i, records[i][0], id)
+		}
+	}
+
+	// A file with different columns is not appended to
+	exp = NewTableExporter(db, "events", []string{"id"}, outputDir)
+	exp.IncrementalColumn = "id"
+	if err := exp.Export(); err == nil {
+		t.Error("Export() expected error for mismatched columns, got nil")
+	}
+
+	// The watermark column must be exported
+	exp = NewTableExporter(db, "events", []string{"name"}, outputDir)
+	exp.IncrementalColumn = "id"
+	if err := exp.Export(); err == nil {
+		t.Error("Export() expected error for a missing incremental column, got nil")
+	}
+}
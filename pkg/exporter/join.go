@@ -0,0 +1,101 @@
+package exporter
+
+import (
+	"fmt"
+	"slices"
+	"sql2csv/pkg/database"
+	"strconv"
+	"strings"
+)
+
+// Join adds columns of another table to an export with a LEFT JOIN on
+// Column = Table.JoinColumn, e.g. to export a customer's name next to an
+// order's customer_id
+type Join struct {
+	Table      string   // joined table
+	Column     string   // column of the exported table
+	JoinColumn string   // column of Table matched against Column
+	Columns    []string // columns of Table to export
+}
+
+// JoinColumnName returns the name a joined column is exported under
+func JoinColumnName(table, column string) string {
+	return table + "_" + column
+}
+
+// addJoinColumns inserts the joined columns after the column each join is
+// on. It runs before the column filters, so the join column itself can be
+// excluded and the joined columns take its place.
+func (e *TableExporter) addJoinColumns() error {
+	if len(e.Joins) == 0 || e.joinExprs != nil {
+		return nil
+	}
+
+	exprs := make(map[string]string)
+	after := make(map[string][]string) // joined columns by the column they follow
+	for i, join := range e.Joins {
+		if join.Table == e.tableName {
+			return fmt.Errorf("table %s can't be joined to itself", e.tableName)
+		}
+		for _, other := range e.Joins[:i] {
+			if other.Table == join.Table {
+				return fmt.Errorf("table %s is joined more than once", join.Table)
+			}
+		}
+		if !slices.Contains(e.columns, join.Column) {
+			return fmt.Errorf("join column %s not found in table %s", join.Column, e.tableName)
+		}
+		if join.JoinColumn == "" || len(join.Columns) == 0 {
+			return fmt.Errorf("join of table %s needs a join column and columns to export", join.Table)
+		}
+
+		for _, column := range join.Columns {
+			name := JoinColumnName(join.Table, column)
+			if _, ok := exprs[name]; ok || slices.Contains(e.columns, name) {
+				return fmt.Errorf("joined column %s.%s clashes with column %s of the export", join.Table, column, name)
+			}
+			exprs[name] = fmt.Sprintf("%s.%s AS %s",
+				e.quoteIdentifier(join.Table), e.quoteIdentifier(column), e.quoteIdentifier(name))
+			after[join.Column] = append(after[join.Column], name)
+		}
+	}
+
+	columns := make([]string, 0, len(e.columns)+len(exprs))
+	for _, column := range e.columns {
+		columns = append(columns, column)
+		columns = append(columns, after[column]...)
+	}
+	e.columns, e.joinExprs = columns, exprs
+	return nil
+}
+
+// columnRef returns a column of the exported table for use in the query,
+// qualified with the table name when other tables are joined
+func (e *TableExporter) columnRef(column string) string {
+	if len(e.Joins) == 0 {
+		return e.quoteIdentifier(column)
+	}
+	return e.quoteIdentifier(e.tableName) + "." + e.quoteIdentifier(column)
+}
+
+// pageSample reports whether TableSample picks pages with TABLESAMPLE
+// SYSTEM, which Postgres only supports on tables
+func (e *TableExporter) pageSample() bool {
+	return e.DBType == database.Postgres && !e.View
+}
+
+// fromClause returns the exported table, sampled on Postgres, and its joins
+func (e *TableExporter) fromClause() string {
+	var b strings.Builder
+	b.WriteString(e.quoteIdentifier(e.tableName))
+	if e.TableSample > 0 && e.pageSample() {
+		fmt.Fprintf(&b, " TABLESAMPLE SYSTEM (%s)", strconv.FormatFloat(e.TableSample, 'f', -1, 64))
+	}
+	for _, join := range e.Joins {
+		fmt.Fprintf(&b, " LEFT JOIN %s ON %s = %s.%s",
+			e.quoteIdentifier(join.Table),
+			e.columnRef(join.Column),
+			e.quoteIdentifier(join.Table), e.quoteIdentifier(join.JoinColumn))
+	}
+	return b.String()
+}
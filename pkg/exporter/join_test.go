@@ -0,0 +1,115 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"os"
+	"sql2csv/pkg/database"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestTableExporter_Joins(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT, country TEXT);
+		CREATE TABLE orders (id INTEGER PRIMARY KEY, customer_id INTEGER, total REAL);
+		INSERT INTO customers VALUES (1, 'Ann', 'DE'), (2, 'Bob', 'FR');
+		INSERT INTO orders VALUES (1, 2, 9.5), (2, 1, 3), (3, NULL, 1);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test tables: %v", err)
+	}
+
+	customers := Join{Table: "customers", Column: "customer_id", JoinColumn: "id", Columns: []string{"name", "country"}}
+
+	tests := []struct {
+		name    string
+		joins   []Join
+		exclude []string
+		where   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "Joined columns follow the join column",
+			joins: []Join{customers},
+			want:  "id,customer_id,customers_name,customers_country,total\n1,2,Bob,FR,9.5\n2,1,Ann,DE,3\n3,,,,1\n",
+		},
+		{
+			name:    "Joined columns replace an excluded join column",
+			joins:   []Join{customers},
+			exclude: []string{"customer_id", "customers_country"},
+			want:    "id,customers_name,customers_country,total\n1,Bob,FR,9.5\n2,Ann,DE,3\n3,,,1\n",
+		},
+		{
+			name:  "Where can refer to the joined table",
+			joins: []Join{customers},
+			where: "customers.country = 'DE'",
+			want:  "id,customer_id,customers_name,customers_country,total\n2,1,Ann,DE,3\n",
+		},
+		{
+			name:    "Unknown join column",
+			joins:   []Join{{Table: "customers", Column: "client_id", JoinColumn: "id", Columns: []string{"name"}}},
+			wantErr: true,
+		},
+		{
+			name:    "Self join",
+			joins:   []Join{{Table: "orders", Column: "customer_id", JoinColumn: "id", Columns: []string{"total"}}},
+			wantErr: true,
+		},
+		{
+			name:    "Table joined twice",
+			joins:   []Join{customers, customers},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp := NewTableExporter(db, "orders", []string{"id", "customer_id", "total"}, "")
+			exp.DBType = database.SQLite
+			exp.Joins = tt.joins
+			exp.ExcludeColumns = tt.exclude
+			exp.Where = tt.where
+			var buf bytes.Buffer
+			err := exp.ExportStream(context.Background(), &buf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExportStream() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("ExportStream() wrote %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestTableExporter_JoinQuery(t *testing.T) {
+	exp := NewTableExporter(nil, "orders", []string{"id", "customer_id"}, ".")
+	exp.DBType = database.MySQL
+	exp.Joins = []Join{{Table: "customers", Column: "customer_id", JoinColumn: "id", Columns: []string{"name"}}}
+
+	got, err := exp.Query()
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	want := "SELECT `orders`.`id`, `orders`.`customer_id`, `customers`.`name` AS `customers_name` " +
+		"FROM `orders` LEFT JOIN `customers` ON `orders`.`customer_id` = `customers`.`id`"
+	if got != want {
+		t.Errorf("Query() = %q, want %q", got, want)
+	}
+}
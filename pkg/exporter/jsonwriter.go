@@ -0,0 +1,185 @@
+package exporter
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// jsonBatchWriter writes rows as a JSON array of objects keyed by column
+// name, or as newline-delimited JSON objects. Objects are streamed as they
+// arrive, one per line, or indented over several lines when pretty.
+type jsonBatchWriter struct {
+	writer  *bufio.Writer
+	lines   bool     // JSONL: no enclosing array
+	pretty  bool     // indent the array's objects like json.MarshalIndent
+	keys    []string // column names encoded as JSON strings
+	numeric []bool   // columns whose text values are numbers
+	binary  []bool   // columns whose bytes are base64-encoded
+	rows    int64
+}
+
+func newJSONBatchWriter(w io.Writer, lines, pretty bool) *jsonBatchWriter {
+	return &jsonBatchWriter{writer: bufio.NewWriter(w), lines: lines, pretty: pretty}
+}
+
+func (j *jsonBatchWriter) WriteHeader(columns []string, types []*sql.ColumnType) error {
+	j.keys = make([]string, len(columns))
+	j.numeric = make([]bool, len(columns))
+	j.binary = make([]bool, len(columns))
+	for i, column := range columns {
+		key, err := json.Marshal(column)
+		if err != nil {
+			return err
+		}
+		j.keys[i] = string(key)
+		if i < len(types) && types[i] != nil {
+			j.numeric[i] = isNumericType(types[i].DatabaseTypeName())
+			j.binary[i] = isBinaryType(types[i].DatabaseTypeName())
+		}
+	}
+	if j.lines {
+		return nil
+	}
+	_, err := j.writer.WriteString("[")
+	return err
+}
+
+func (j *jsonBatchWriter) WriteBatch(rows [][]interface{}) error {
+	// The separators around the objects and their fields
+	open, comma, colon, end := "{", ",", ":", "}"
+	if j.pretty {
+		open, comma, colon, end = "  {\n    ", ",\n    ", ": ", "\n  }"
+		if len(j.keys) == 0 {
+			open, end = "  {", "}"
+		}
+	}
+
+	for _, row := range rows {
+		sep := ",\n"
+		switch {
+		case j.lines:
+			sep = ""
+		case j.rows == 0:
+			sep = "\n"
+		}
+		if _, err := j.writer.WriteString(sep + open); err != nil {
+			return err
+		}
+		for i, val := range row {
+			if i > 0 {
+				if _, err := j.writer.WriteString(comma); err != nil {
+					return err
+				}
+			}
+			value, err := j.jsonValue(i, val)
+			if err != nil {
+				return err
+			}
+			if _, err := j.writer.WriteString(j.keys[i] + colon + value); err != nil {
+				return err
+			}
+		}
+		if _, err := j.writer.WriteString(end); err != nil {
+			return err
+		}
+		if j.lines {
+			if err := j.writer.WriteByte('\n'); err != nil {
+				return err
+			}
+		}
+		j.rows++
+	}
+
+	// Hand complete lines to streaming consumers after every batch
+	if j.lines {
+		return j.writer.Flush()
+	}
+	return nil
+}
+
+func (j *jsonBatchWriter) Close() error {
+	if j.lines {
+		return j.writer.Flush()
+	}
+	end := "]\n"
+	if j.rows > 0 {
+		end = "\n]\n"
+	}
+	if _, err := j.writer.WriteString(end); err != nil {
+		return err
+	}
+	return j.writer.Flush()
+}
+
+func (j *jsonBatchWriter) flush() error {
+	return j.writer.Flush()
+}
+
+// jsonValue encodes a scanned value of column i. Numeric driver types become
+// JSON numbers, as do text values of numeric columns, which drivers such as
+// MySQL's return as bytes.
+func (j *jsonBatchWriter) jsonValue(i int, v interface{}) (string, error) {
+	switch val := v.(type) {
+	case nil:
+		return "null", nil
+	case float64:
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			// JSON has no representation for these
+			return "null", nil
+		}
+	case float32:
+		if math.IsNaN(float64(val)) || math.IsInf(float64(val), 0) {
+			return "null", nil
+		}
+	case []byte:
+		if j.numeric[i] && isJSONNumber(string(val)) {
+			return string(val), nil
+		}
+		// Binary columns, and bytes that aren't text, are marshaled as base64
+		if !j.binary[i] && utf8.Valid(val) {
+			v = string(val)
+		}
+	}
+
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// isNumericType reports whether a driver type name is an integer or float
+// type
+func isNumericType(name string) bool {
+	name = strings.ToUpper(name)
+	name = strings.TrimPrefix(name, "UNSIGNED ")
+	switch name {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "BIGINT",
+		"INT2", "INT4", "INT8", "FLOAT", "FLOAT4", "FLOAT8", "DOUBLE", "REAL":
+		return true
+	}
+	return false
+}
+
+// isBinaryType reports whether a driver type name is a binary type
+func isBinaryType(name string) bool {
+	switch strings.ToUpper(name) {
+	case "BLOB", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB", "BINARY", "VARBINARY", "BYTEA",
+		"GEOMETRY":
+		return true
+	}
+	return false
+}
+
+// isJSONNumber reports whether s is a valid JSON number
+func isJSONNumber(s string) bool {
+	if s == "" || (s[0] != '-' && (s[0] < '0' || s[0] > '9')) {
+		return false
+	}
+	return json.Valid([]byte(s))
+}
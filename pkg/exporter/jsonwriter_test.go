@@ -0,0 +1,236 @@
+package exporter
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestTableExporter_ExportJSON(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE test_table (id INTEGER PRIMARY KEY, name TEXT, score REAL, note TEXT);
+		INSERT INTO test_table (name, score, note) VALUES ('x', 1.5, NULL), ('say "hi"', -2, 'a,b');
+		CREATE TABLE empty_table (id INTEGER);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	outputDir, err := os.MkdirTemp("", "json_output")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	exp := NewTableExporter(db, "test_table", []string{"id", "name", "score", "note"}, outputDir)
+	exp.Format = JSON
+	if err := exp.Export(); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	if filepath.Base(exp.OutputPath()) != "test_table.json" {
+		t.Errorf("OutputPath() = %s, want test_table.json", exp.OutputPath())
+	}
+
+	content, err := os.ReadFile(exp.OutputPath())
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+
+	want := `[
+{"id":1,"name":"x","score":1.5,"note":null},
+{"id":2,"name":"say \"hi\"","score":-2,"note":"a,b"}
+]
+`
+	if string(content) != want {
+		t.Errorf("JSON output = %s, want %s", content, want)
+	}
+
+	var objects []map[string]interface{}
+	if err := json.Unmarshal(content, &objects); err != nil {
+		t.Fatalf("Output is not valid JSON: %v", err)
+	}
+
+	// An empty table is an empty array
+	exp = NewTableExporter(db, "empty_table", []string{"id"}, outputDir)
+	exp.Format = JSON
+	if err := exp.Export(); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	content, err = os.ReadFile(exp.OutputPath())
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	if string(content) != "[]\n" {
+		t.Errorf("JSON output = %q, want %q", content, "[]\n")
+	}
+}
+
+func TestTableExporter_ExportPrettyJSON(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE test_table (id INTEGER PRIMARY KEY, name TEXT, note TEXT);
+		INSERT INTO test_table (name, note) VALUES ('x', NULL), ('say "hi"', 'a,b');
+		CREATE TABLE empty_table (id INTEGER);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	outputDir, err := os.MkdirTemp("", "json_output")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	export := func(table string, columns []string, pretty bool) []byte {
+		exp := NewTableExporter(db, table, columns, outputDir)
+		exp.Format = JSON
+		exp.PrettyJSON = pretty
+		if err := exp.Export(); err != nil {
+			t.Fatalf("Export() error = %v", err)
+		}
+		content, err := os.ReadFile(exp.OutputPath())
+		if err != nil {
+			t.Fatalf("Failed to read output file: %v", err)
+		}
+		if !json.Valid(content) {
+			t.Fatalf("Output with PrettyJSON = %v is not valid JSON: %s", pretty, content)
+		}
+		return content
+	}
+
+	for _, table := range []string{"test_table", "empty_table"} {
+		columns := []string{"id"}
+		if table == "test_table" {
+			columns = []string{"id", "name", "note"}
+		}
+		compact := export(table, columns, false)
+		pretty := export(table, columns, true)
+
+		// Pretty output is the compact output indented like MarshalIndent
+		var want bytes.Buffer
+		if err := json.Indent(&want, compact, "", "  "); err != nil {
+			t.Fatalf("Failed to indent compact output: %v", err)
+		}
+		if string(pretty) != want.String() {
+			t.Errorf("Pretty output of %s = %s, want %s", table, pretty, want.String())
+		}
+		if table == "test_table" && bytes.Count(compact, []byte("\n")) != 4 {
+			t.Errorf("Compact output = %s, want one object per line", compact)
+		}
+	}
+}
+
+func TestJSONValue(t *testing.T) {
+	writer := &jsonBatchWriter{numeric: []bool{false, true, false}, binary: []bool{false, false, true}}
+
+	tests := []struct {
+		name   string
+		column int
+		input  interface{}
+		want   string
+	}{
+		{name: "Nil", column: 0, input: nil, want: "null"},
+		{name: "Integer", column: 0, input: int64(42), want: "42"},
+		{name: "Bool", column: 0, input: true, want: "true"},
+		{name: "Text bytes", column: 0, input: []byte("42"), want: `"42"`},
+		{name: "Numeric column bytes", column: 1, input: []byte("-1.25e3"), want: "-1.25e3"},
+		{name: "Numeric column non-number", column: 1, input: []byte("abc"), want: `"abc"`},
+		{name: "Binary bytes", column: 0, input: []byte{0xff, 0x00}, want: `"/wA="`},
+		{name: "Binary column text bytes", column: 2, input: []byte("hi"), want: `"aGk="`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := writer.jsonValue(tt.column, tt.input)
+			if err != nil {
+				t.Fatalf("jsonValue() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("jsonValue() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTableExporter_ExportJSONL(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE test_table (id INTEGER PRIMARY KEY, name TEXT, data BLOB);
+		INSERT INTO test_table (name, data) VALUES ('x', X'0001'), (NULL, NULL);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	outputDir, err := os.MkdirTemp("", "json_output")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	exp := NewTableExporter(db, "test_table", []string{"id", "name", "data"}, outputDir)
+	exp.Format = JSONL
+	if err := exp.Export(); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	if filepath.Base(exp.OutputPath()) != "test_table.jsonl" {
+		t.Errorf("OutputPath() = %s, want test_table.jsonl", exp.OutputPath())
+	}
+
+	content, err := os.ReadFile(exp.OutputPath())
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+
+	want := `{"id":1,"name":"x","data":"AAE="}
+{"id":2,"name":null,"data":null}
+`
+	if string(content) != want {
+		t.Errorf("JSONL output = %s, want %s", content, want)
+	}
+}
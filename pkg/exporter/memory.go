@@ -0,0 +1,71 @@
+package exporter
+
+import (
+	"context"
+	"runtime"
+	"time"
+)
+
+// minAdaptiveBatchSize is the smallest batch an adaptive export shrinks to
+const minAdaptiveBatchSize = 10
+
+// maxMemoryPauses bounds how long an export waits for memory to be freed,
+// since other exports of the same process may hold it
+const maxMemoryPauses = 50
+
+// heapInUse returns the bytes of allocated heap objects; it is a variable so
+// tests can simulate memory pressure
+var heapInUse = func() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+// memoryPause is how long an export waits before checking memory again
+var memoryPause = 100 * time.Millisecond
+
+// memoryGovernor adapts the batch size of an export to the heap size
+type memoryGovernor struct {
+	limit uint64
+	max   int
+	size  int
+
+	// stuck is set when a pause didn't bring the heap under the limit, so
+	// the export carries on at the smallest batch size instead of pausing
+	// for every batch
+	stuck bool
+}
+
+func newMemoryGovernor(limit uint64, batchSize int) *memoryGovernor {
+	return &memoryGovernor{limit: limit, max: batchSize, size: batchSize}
+}
+
+// next returns the size of the next batch. Above the limit the batch is
+// halved and, once it is as small as it gets, the export pauses until the
+// garbage collector has brought the heap back under the limit. Below half
+// the limit the batch grows back towards its configured size.
+func (g *memoryGovernor) next(ctx context.Context) int {
+	heap := heapInUse()
+	if heap <= g.limit {
+		g.stuck = false
+	}
+
+	switch {
+	case heap > g.limit && g.size > minAdaptiveBatchSize:
+		g.size = max(g.size/2, minAdaptiveBatchSize)
+	case heap > g.limit && !g.stuck:
+		for i := 0; i < maxMemoryPauses && heap > g.limit; i++ {
+			runtime.GC()
+			select {
+			case <-ctx.Done():
+				return g.size
+			case <-time.After(memoryPause):
+			}
+			heap = heapInUse()
+		}
+		g.stuck = heap > g.limit
+	case heap < g.limit/2 && g.size < g.max:
+		g.size = min(g.size*2, g.max)
+	}
+	return g.size
+}
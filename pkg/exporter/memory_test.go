@@ -0,0 +1,98 @@
+package exporter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestMemoryGovernor_Next(t *testing.T) {
+	originalHeap, originalPause := heapInUse, memoryPause
+	defer func() { heapInUse, memoryPause = originalHeap, originalPause }()
+	memoryPause = time.Millisecond
+
+	// Heap sizes seen by successive checks, in MB against a 100MB limit
+	heaps := []uint64{150, 150, 150, 150, 150, 150, 150, 120, 90, 40, 40, 40, 40, 40, 40, 40}
+	checks := 0
+	heapInUse = func() uint64 {
+		heap := heaps[min(checks, len(heaps)-1)]
+		checks++
+		return heap << 20
+	}
+
+	g := newMemoryGovernor(100<<20, 1000)
+	var got []int
+	for checks < len(heaps) {
+		got = append(got, g.next(context.Background()))
+	}
+
+	// Halve to the minimum, pause until the heap drops under the limit, hold
+	// between half the limit and the limit, then grow back
+	want := []int{500, 250, 125, 62, 31, 15, 10, 10, 20, 40, 80, 160, 320, 640, 1000}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("batch sizes = %v, want %v", got, want)
+	}
+}
+
+func TestTableExporter_MemoryLimit(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE test_table (id INTEGER PRIMARY KEY);
+		WITH RECURSIVE seq(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM seq WHERE n < 1000)
+		INSERT INTO test_table (id) SELECT n FROM seq;
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	// Memory stays above the limit, so the export pauses once and then
+	// carries on at the smallest batch size
+	originalHeap, originalPause := heapInUse, memoryPause
+	defer func() { heapInUse, memoryPause = originalHeap, originalPause }()
+	memoryPause = time.Millisecond
+	checks := 0
+	heapInUse = func() uint64 {
+		checks++
+		return 2 << 20
+	}
+
+	var calls []int64
+	exp := NewTableExporter(db, "test_table", []string{"id"}, "")
+	exp.BatchSize = 400
+	exp.MemoryLimit = 1 << 20
+	exp.OnProgress = func(rowsWritten int64) { calls = append(calls, rowsWritten) }
+	if err := exp.ExportStream(context.Background(), io.Discard); err != nil {
+		t.Fatalf("ExportStream() error = %v", err)
+	}
+
+	// Every row is exported while the batches shrink
+	want := []int64{400, 600, 700, 750, 775, 787, 797, 807}
+	if len(calls) < len(want) || fmt.Sprint(calls[:len(want)]) != fmt.Sprint(want) {
+		t.Errorf("OnProgress calls = %v, want them to start with %v", calls, want)
+	}
+	if exp.Stats().Rows != 1000 {
+		t.Errorf("Stats().Rows = %d, want 1000", exp.Stats().Rows)
+	}
+	// One check per batch, plus those of the single pause
+	if batches := len(calls) - 1; checks != batches+maxMemoryPauses {
+		t.Errorf("heap checked %d times for %d batches, want a single pause", checks, batches)
+	}
+}
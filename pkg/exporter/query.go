@@ -0,0 +1,80 @@
+package exporter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// QueryExporter exports the result of an arbitrary query to a CSV file
+type QueryExporter struct {
+	db         *sql.DB
+	query      string
+	outputPath string
+
+	// OnTempFile, when set, is called with the path of the temporary file
+	// the result is written to before it is renamed to the output path
+	OnTempFile func(path string)
+
+	stats Stats
+}
+
+// NewQueryExporter creates a new QueryExporter instance
+func NewQueryExporter(db *sql.DB, query string, outputPath string) *QueryExporter {
+	return &QueryExporter{
+		db:         db,
+		query:      query,
+		outputPath: outputPath,
+	}
+}
+
+// Stats returns the row counts of the last export
+func (q *QueryExporter) Stats() Stats {
+	return q.stats
+}
+
+// Export runs the query and writes its result to the output path
+func (q *QueryExporter) Export() error {
+	return q.ExportContext(context.Background())
+}
+
+// ExportContext is like Export but stops early when ctx is done
+func (q *QueryExporter) ExportContext(ctx context.Context) error {
+	rows, err := q.db.QueryContext(ctx, q.query)
+	if err != nil {
+		return fmt.Errorf("error running query: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("error reading columns: %w", err)
+	}
+	if len(columns) == 0 {
+		return fmt.Errorf("query returned no columns")
+	}
+
+	file, err := createAtomic(q.outputPath, q.OnTempFile)
+	if err != nil {
+		return err
+	}
+
+	// The result set is written like a table named after the query
+	exp := &TableExporter{db: q.db, tableName: "query", columns: columns, Format: CSV}
+	writer, err := exp.newRowWriter(file)
+	if err != nil {
+		file.abort()
+		return err
+	}
+
+	err = exp.writeRows(ctx, rows, writer)
+	q.stats = exp.Stats()
+	if err != nil && !keepPartial(ctx) {
+		file.abort()
+		return err
+	}
+	if commitErr := file.commit(); commitErr != nil {
+		return commitErr
+	}
+	return err
+}
@@ -0,0 +1,87 @@
+package exporter
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestQueryExporter_Export(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);
+		CREATE TABLE orders (id INTEGER PRIMARY KEY, user_id INTEGER, total REAL);
+		INSERT INTO users (id, name) VALUES (1, 'ann'), (2, 'bob');
+		INSERT INTO orders (user_id, total) VALUES (1, 10), (1, 5), (2, 7);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test tables: %v", err)
+	}
+
+	outputDir, err := os.MkdirTemp("", "csv_output")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	outputPath := filepath.Join(outputDir, "totals.csv")
+	exp := NewQueryExporter(db, `
+		SELECT u.name, SUM(o.total) AS total
+		FROM users u JOIN orders o ON o.user_id = u.id
+		GROUP BY u.name ORDER BY u.name`, outputPath)
+	if err := exp.Export(); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	if exp.Stats().Rows != 2 {
+		t.Errorf("Stats().Rows = %d, want 2", exp.Stats().Rows)
+	}
+
+	file, err := os.Open(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to open output file: %v", err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV file: %v", err)
+	}
+	want := [][]string{{"name", "total"}, {"ann", "15"}, {"bob", "7"}}
+	if len(records) != len(want) {
+		t.Fatalf("CSV records = %v, want %v", records, want)
+	}
+	for i := range want {
+		for j := range want[i] {
+			if records[i][j] != want[i][j] {
+				t.Errorf("records[%d][%d] = %s, want %s", i, j, records[i][j], want[i][j])
+			}
+		}
+	}
+
+	// Statements without a result set are rejected without creating a file
+	noColumnsPath := filepath.Join(outputDir, "update.csv")
+	exp = NewQueryExporter(db, "UPDATE users SET name = name", noColumnsPath)
+	if err := exp.Export(); err == nil {
+		t.Error("Export() expected error for a statement without columns, got nil")
+	}
+	if _, err := os.Stat(noColumnsPath); !os.IsNotExist(err) {
+		t.Errorf("Export() created %s for a statement without columns", noColumnsPath)
+	}
+}
@@ -0,0 +1,111 @@
+package exporter
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Schema lists the columns each table's export is expected to have, in
+// order. Its JSON form is
+//
+//	{"tables": {"users": [{"name": "id", "type": "INTEGER"}, {"name": "email"}]}}
+//
+// A column's type is optional; when given it is compared with the type name
+// the driver reports, e.g. INT4 for a PostgreSQL integer.
+type Schema struct {
+	Tables map[string][]SchemaColumn `json:"tables"`
+}
+
+// SchemaColumn is one expected column of a Schema
+type SchemaColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"`
+}
+
+// SchemaError describes how an export's columns differ from its schema
+type SchemaError struct {
+	Table string
+	Diffs []string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("columns of table %s don't match the schema:\n  %s", e.Table, strings.Join(e.Diffs, "\n  "))
+}
+
+// LoadSchema reads a Schema from a JSON file
+func LoadSchema(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema file: %w", err)
+	}
+
+	var schema Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("error parsing schema file %s: %w", path, err)
+	}
+	for table, columns := range schema.Tables {
+		for i, column := range columns {
+			if column.Name == "" {
+				return nil, fmt.Errorf("column %d of table %s in schema file %s has no name", i+1, table, path)
+			}
+		}
+	}
+	return &schema, nil
+}
+
+// validateSchema compares the exported columns and their driver types with
+// the expected ones, returning a *SchemaError listing every difference
+func validateSchema(table string, want []SchemaColumn, columns []string, types []*sql.ColumnType) error {
+	var diffs []string
+	wantNames := make([]string, len(want))
+	for i, column := range want {
+		wantNames[i] = column.Name
+		if !slices.Contains(columns, column.Name) {
+			diffs = append(diffs, fmt.Sprintf("missing column %s", column.Name))
+		}
+	}
+	for _, column := range columns {
+		if !slices.Contains(wantNames, column) {
+			diffs = append(diffs, fmt.Sprintf("unexpected column %s", column))
+		}
+	}
+
+	// Positions only mean something once the same columns are there
+	if len(diffs) == 0 {
+		for i, column := range columns {
+			if column != wantNames[i] {
+				diffs = append(diffs, fmt.Sprintf("column %d is %s, want %s", i+1, column, wantNames[i]))
+			}
+		}
+	}
+
+	for _, column := range want {
+		i := slices.Index(columns, column.Name)
+		if column.Type == "" || i < 0 || i >= len(types) || types[i] == nil {
+			continue
+		}
+		if got := types[i].DatabaseTypeName(); !sameType(got, column.Type) {
+			diffs = append(diffs, fmt.Sprintf("column %s has type %s, want %s", column.Name, got, column.Type))
+		}
+	}
+
+	if len(diffs) > 0 {
+		return &SchemaError{Table: table, Diffs: diffs}
+	}
+	return nil
+}
+
+// sameType compares type names case-insensitively. A wanted type without a
+// size, e.g. VARCHAR, matches any size of it.
+func sameType(got, want string) bool {
+	got, want = strings.ToUpper(strings.TrimSpace(got)), strings.ToUpper(strings.TrimSpace(want))
+	if !strings.Contains(want, "(") {
+		got, _, _ = strings.Cut(got, "(")
+		got = strings.TrimSpace(got)
+	}
+	return got == want
+}
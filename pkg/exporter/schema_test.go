@@ -0,0 +1,127 @@
+package exporter
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestLoadSchema(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		content string
+		want    *Schema
+		wantErr bool
+	}{
+		{
+			name:    "Names and types",
+			content: `{"tables": {"users": [{"name": "id", "type": "INTEGER"}, {"name": "email"}]}}`,
+			want: &Schema{Tables: map[string][]SchemaColumn{
+				"users": {{Name: "id", Type: "INTEGER"}, {Name: "email"}},
+			}},
+		},
+		{name: "Column without a name", content: `{"tables": {"users": [{"type": "TEXT"}]}}`, wantErr: true},
+		{name: "Not JSON", content: `users: id, email`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".json")
+			if err := os.WriteFile(path, []byte(tt.content), 0666); err != nil {
+				t.Fatalf("Failed to write schema file: %v", err)
+			}
+			got, err := LoadSchema(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadSchema() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadSchema() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTableExporter_Schema(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE users (id INTEGER PRIMARY KEY, email VARCHAR(255), created TEXT);
+		INSERT INTO users (email, created) VALUES ('a@example.com', '2024-01-01');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		schema    []SchemaColumn
+		wantDiffs []string
+	}{
+		{
+			name:   "Names match",
+			schema: []SchemaColumn{{Name: "id"}, {Name: "email"}, {Name: "created"}},
+		},
+		{
+			name:   "Types match",
+			schema: []SchemaColumn{{Name: "id", Type: "integer"}, {Name: "email", Type: "VARCHAR"}, {Name: "created", Type: "TEXT"}},
+		},
+		{
+			name:      "Missing and unexpected columns",
+			schema:    []SchemaColumn{{Name: "id"}, {Name: "mail"}, {Name: "created"}, {Name: "updated"}},
+			wantDiffs: []string{"missing column mail", "missing column updated", "unexpected column email"},
+		},
+		{
+			name:      "Columns out of order",
+			schema:    []SchemaColumn{{Name: "email"}, {Name: "id"}, {Name: "created"}},
+			wantDiffs: []string{"column 1 is id, want email", "column 2 is email, want id"},
+		},
+		{
+			name:      "Type changed",
+			schema:    []SchemaColumn{{Name: "id"}, {Name: "email"}, {Name: "created", Type: "DATETIME"}},
+			wantDiffs: []string{"column created has type TEXT, want DATETIME"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputDir := t.TempDir()
+			exp := NewTableExporter(db, "users", []string{"id", "email", "created"}, outputDir)
+			exp.Schema = tt.schema
+			err := exp.Export()
+
+			if tt.wantDiffs == nil {
+				if err != nil {
+					t.Fatalf("Export() error = %v", err)
+				}
+				return
+			}
+			var schemaErr *SchemaError
+			if !errors.As(err, &schemaErr) {
+				t.Fatalf("Export() error = %v, want a *SchemaError", err)
+			}
+			if !reflect.DeepEqual(schemaErr.Diffs, tt.wantDiffs) {
+				t.Errorf("Diffs = %q, want %q", schemaErr.Diffs, tt.wantDiffs)
+			}
+			if _, err := os.Stat(exp.OutputPath()); !os.IsNotExist(err) {
+				t.Errorf("output file exists after a schema mismatch, stat error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package exporter
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// PartPath returns the path of the nth file, counting from 1, of an export
+// split with MaxRowsPerFile or MaxBytesPerFile, e.g. users_0001.csv. Numbers
+// are zero-padded to four digits so the parts sort in order.
+func (e *TableExporter) PartPath(n int) string {
+	name := fmt.Sprintf("%s_%04d.%s", e.fileName(), n, e.extension())
+	if e.Compress {
+		name += ".gz"
+	}
+	return filepath.Join(e.outputDir, name)
+}
+
+// OutputPaths returns the paths of the files the last export wrote: the
+// parts of a split export, or OutputPath
+func (e *TableExporter) OutputPaths() []string {
+	if e.split() {
+		return e.parts
+	}
+	return []string{e.OutputPath()}
+}
+
+// split reports whether the export is split into several files
+func (e *TableExporter) split() bool {
+	return e.MaxRowsPerFile > 0 || e.MaxBytesPerFile > 0
+}
+
+// exportParts writes the table to PartPath files of at most MaxRowsPerFile
+// rows and MaxBytesPerFile bytes each. Like a single file, the parts only
+// replace existing files once the export is complete or out of time.
+func (e *TableExporter) exportParts(ctx context.Context) error {
+	e.parts = nil
+	writer := &splitWriter{e: e}
+	err := e.ExportToContext(ctx, writer)
+	if err != nil && !keepPartial(ctx) {
+		writer.abort()
+		return err
+	}
+	if commitErr := writer.commit(); commitErr != nil {
+		return commitErr
+	}
+	return err
+}
+
+// flusher is implemented by the writers whose output can be split by size
+type flusher interface {
+	flush() error
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// splitWriter writes rows to a new part file, headed by the header rows,
+// whenever the current one is full
+type splitWriter struct {
+	e       *TableExporter
+	columns []string
+	types   []*sql.ColumnType
+
+	files  []*atomicFile // closed once full, except the current part
+	gz     *gzip.Writer
+	writer RowWriter       // writer of the current part, nil once closed
+	size   *countingWriter // bytes of the current part before compression
+	rows   int             // rows in the current part
+
+	// Splitting by size encodes every row a second time, into measure, to
+	// learn its size before it is written
+	measure     RowWriter
+	measured    *countingWriter
+	reserve     int64 // bytes Close still appends to a part
+	pending     [][]interface{}
+	pendingSize int64
+}
+
+func (s *splitWriter) WriteHeader(columns []string, types []*sql.ColumnType) error {
+	s.columns, s.types = columns, types
+	if s.e.MaxBytesPerFile > 0 {
+		s.measured = &countingWriter{w: io.Discard}
+		measure, err := s.e.newRowWriter(s.measured)
+		if err != nil {
+			return err
+		}
+		if err := measure.WriteHeader(columns, types); err != nil {
+			return err
+		}
+		s.measure = measure
+		if s.e.format() == JSON {
+			s.reserve = int64(len("\n]\n"))
+		}
+	}
+	// An empty table still gets a part with the header
+	return s.openPart()
+}
+
+func (s *splitWriter) WriteBatch(rows [][]interface{}) error {
+	for _, row := range rows {
+		var size int64
+		if s.measure != nil {
+			var err error
+			if size, err = s.rowSize(row); err != nil {
+				return err
+			}
+		}
+		if s.full(size) {
+			if err := s.writePending(); err != nil {
+				return err
+			}
+			if err := s.closePart(); err != nil {
+				return err
+			}
+			if err := s.openPart(); err != nil {
+				return err
+			}
+		}
+		s.pending = append(s.pending, row)
+		s.pendingSize += size
+	}
+	return s.writePending()
+}
+
+func (s *splitWriter) Close() error {
+	return s.closePart()
+}
+
+// rowSize returns the number of bytes row takes in the output
+func (s *splitWriter) rowSize(row []interface{}) (int64, error) {
+	before := s.measured.n
+	if err := s.measure.WriteBatch([][]interface{}{row}); err != nil {
+		return 0, err
+	}
+	if err := s.measure.(flusher).flush(); err != nil {
+		return 0, err
+	}
+	return s.measured.n - before, nil
+}
+
+// full reports whether the current part has no room for a row of size
+// bytes. A part always takes at least one row.
+func (s *splitWriter) full(size int64) bool {
+	rows := s.rows + len(s.pending)
+	if rows == 0 {
+		return false
+	}
+	if s.e.MaxRowsPerFile > 0 && rows >= s.e.MaxRowsPerFile {
+		return true
+	}
+	return s.e.MaxBytesPerFile > 0 && s.size.n+s.pendingSize+size+s.reserve > s.e.MaxBytesPerFile
+}
+
+// writePending writes the rows that fit in the current part as one batch
+func (s *splitWriter) writePending() error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	if err := s.writer.WriteBatch(s.pending); err != nil {
+		return err
+	}
+	s.rows += len(s.pending)
+	s.pending = s.pending[:0]
+	s.pendingSize = 0
+	if s.measure != nil {
+		// Count what the writer buffered, so the part's size is exact
+		return s.writer.(flusher).flush()
+	}
+	return nil
+}
+
+// openPart creates the next part file and writes its header
+func (s *splitWriter) openPart() error {
+	path := s.e.PartPath(len(s.files) + 1)
+	file, err := createAtomic(path, s.e.OnTempFile)
+	if err != nil {
+		return err
+	}
+	s.files = append(s.files, file)
+	s.e.parts = append(s.e.parts, path)
+
+	var out io.Writer = file
+	s.gz = nil
+	if s.e.Compress {
+		s.gz = gzip.NewWriter(file)
+		out = s.gz
+	}
+	s.size = &countingWriter{w: out}
+	writer, err := s.e.newRowWriter(s.size)
+	if err != nil {
+		return err
+	}
+	if err := writer.WriteHeader(s.columns, s.types); err != nil {
+		return err
+	}
+	s.writer, s.rows = writer, 0
+	if s.measure != nil {
+		return writer.(flusher).flush()
+	}
+	return nil
+}
+
+// closePart flushes the current part and closes its file
+func (s *splitWriter) closePart() error {
+	if s.writer == nil {
+		return nil
+	}
+	writer := s.writer
+	s.writer = nil
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	if s.gz != nil {
+		if err := s.gz.Close(); err != nil {
+			return fmt.Errorf("error closing gzip stream: %w", err)
+		}
+	}
+	if err := s.files[len(s.files)-1].Close(); err != nil {
+		return fmt.Errorf("error closing output file: %w", err)
+	}
+	return nil
+}
+
+// commit moves every part to its destination and removes the parts of an
+// earlier export beyond the last one, so they aren't taken for part of
+// this export
+func (s *splitWriter) commit() error {
+	if err := s.closePart(); err != nil {
+		s.abort()
+		return err
+	}
+	for i, file := range s.files {
+		if err := file.rename(); err != nil {
+			for _, rest := range s.files[i+1:] {
+				os.Remove(rest.Name())
+			}
+			return err
+		}
+	}
+	for n := len(s.files) + 1; ; n++ {
+		if err := os.Remove(s.e.PartPath(n)); err != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// abort removes every part's temporary file
+func (s *splitWriter) abort() {
+	for _, file := range s.files {
+		file.abort()
+	}
+}
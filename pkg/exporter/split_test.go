@@ -0,0 +1,227 @@
+package exporter
+
+import (
+	"cmp"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestTableExporter_MaxRowsPerFile(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);
+		INSERT INTO users (name) VALUES ('a'), ('b'), ('c'), ('d'), ('e');
+		CREATE TABLE empty (id INTEGER PRIMARY KEY, name TEXT);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		table     string
+		maxRows   int
+		batchSize int
+		want      map[string]string
+	}{
+		{
+			name:    "Last part partly filled",
+			table:   "users",
+			maxRows: 2,
+			want: map[string]string{
+				"users_0001.csv": "id,name\n1,a\n2,b\n",
+				"users_0002.csv": "id,name\n3,c\n4,d\n",
+				"users_0003.csv": "id,name\n5,e\n",
+			},
+		},
+		{
+			name:      "Parts spanning batches",
+			table:     "users",
+			maxRows:   3,
+			batchSize: 2,
+			want: map[string]string{
+				"users_0001.csv": "id,name\n1,a\n2,b\n3,c\n",
+				"users_0002.csv": "id,name\n4,d\n5,e\n",
+			},
+		},
+		{
+			name:    "Single part",
+			table:   "users",
+			maxRows: 10,
+			want:    map[string]string{"users_0001.csv": "id,name\n1,a\n2,b\n3,c\n4,d\n5,e\n"},
+		},
+		{
+			name:    "Empty table",
+			table:   "empty",
+			maxRows: 2,
+			want:    map[string]string{"empty_0001.csv": "id,name\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputDir := t.TempDir()
+			// Parts left from an earlier, larger export, and an unrelated
+			// file after a gap in the numbering
+			exp := NewTableExporter(db, tt.table, []string{"id", "name"}, outputDir)
+			stale := []string{exp.PartPath(len(tt.want) + 1), exp.PartPath(len(tt.want) + 2)}
+			unrelated := exp.PartPath(len(tt.want) + 4)
+			for _, path := range append(stale, unrelated) {
+				if err := os.WriteFile(path, []byte("old"), 0666); err != nil {
+					t.Fatalf("Failed to write old part: %v", err)
+				}
+			}
+
+			exp.MaxRowsPerFile = tt.maxRows
+			exp.BatchSize = tt.batchSize
+			if err := exp.Export(); err != nil {
+				t.Fatalf("Export() error = %v", err)
+			}
+
+			got := make(map[string]string)
+			for _, path := range exp.OutputPaths() {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatalf("Failed to read part: %v", err)
+				}
+				got[filepath.Base(path)] = string(data)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parts = %q, want %q", got, tt.want)
+			}
+
+			for _, path := range stale {
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Errorf("old part %s kept, stat error = %v", filepath.Base(path), err)
+				}
+			}
+			if _, err := os.Stat(unrelated); err != nil {
+				t.Errorf("file after a gap removed: %v", err)
+			}
+		})
+	}
+}
+
+func TestTableExporter_MaxRowsPerFileOptions(t *testing.T) {
+	exp := NewTableExporter(nil, "users", []string{"id"}, t.TempDir())
+	exp.MaxRowsPerFile = 10
+	exp.Compress = true
+	if got, want := filepath.Base(exp.PartPath(12)), "users_0012.csv.gz"; got != want {
+		t.Errorf("PartPath(12) = %s, want %s", got, want)
+	}
+
+	exp.IncrementalColumn = "id"
+	if _, err := exp.newRowWriter(nil); err == nil {
+		t.Error("newRowWriter() with IncrementalColumn succeeded, want an error")
+	}
+
+	exp.IncrementalColumn = ""
+	exp.Compress = false
+	exp.MaxBytesPerFile = 1 << 20
+	exp.Format = XLSX
+	if _, err := exp.newRowWriter(nil); err == nil {
+		t.Error("newRowWriter() for XLSX split by size succeeded, want an error")
+	}
+}
+
+func TestTableExporter_MaxBytesPerFile(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);
+		INSERT INTO users (name) VALUES ('a'), ('b'), ('c'), ('long name'), ('e');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		format   Format
+		maxBytes int64
+		maxRows  int
+		want     []string
+	}{
+		{
+			// The header takes 8 bytes and short rows 4
+			name:     "Rows up to the limit",
+			maxBytes: 16,
+			want:     []string{"id,name\n1,a\n2,b\n", "id,name\n3,c\n", "id,name\n4,long name\n", "id,name\n5,e\n"},
+		},
+		{
+			name:     "Row larger than the limit",
+			maxBytes: 12,
+			want:     []string{"id,name\n1,a\n", "id,name\n2,b\n", "id,name\n3,c\n", "id,name\n4,long name\n", "id,name\n5,e\n"},
+		},
+		{
+			name:     "Row limit reached first",
+			maxBytes: 1000,
+			maxRows:  3,
+			want:     []string{"id,name\n1,a\n2,b\n3,c\n", "id,name\n4,long name\n5,e\n"},
+		},
+		{
+			name:     "JSON array closed within the limit",
+			format:   JSON,
+			maxBytes: 50,
+			want: []string{
+				"[\n{\"id\":1,\"name\":\"a\"},\n{\"id\":2,\"name\":\"b\"}\n]\n",
+				"[\n{\"id\":3,\"name\":\"c\"}\n]\n",
+				"[\n{\"id\":4,\"name\":\"long name\"}\n]\n",
+				"[\n{\"id\":5,\"name\":\"e\"}\n]\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp := NewTableExporter(db, "users", []string{"id", "name"}, t.TempDir())
+			exp.Format = cmp.Or(tt.format, CSV)
+			exp.MaxBytesPerFile = tt.maxBytes
+			exp.MaxRowsPerFile = tt.maxRows
+			exp.BatchSize = 2
+			if err := exp.Export(); err != nil {
+				t.Fatalf("Export() error = %v", err)
+			}
+
+			var got []string
+			for _, path := range exp.OutputPaths() {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatalf("Failed to read part: %v", err)
+				}
+				got = append(got, string(data))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parts = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+//go:build unix
+
+package exporter
+
+import (
+	"database/sql"
+	"os"
+	"syscall"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestTableExporter_ManyPartsLowFileLimit(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE events (id INTEGER PRIMARY KEY, name TEXT);
+		WITH RECURSIVE seq(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM seq WHERE n < 200)
+		INSERT INTO events (name) SELECT 'event' || n FROM seq;
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	outputDir, err := os.MkdirTemp("", "csv_output")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	// Leave room for a few more open files than the process has now, far
+	// fewer than the 200 parts, which must not be held open at once
+	probe, err := os.Open(outputDir)
+	if err != nil {
+		t.Fatalf("Failed to open output directory: %v", err)
+	}
+	nextFD := probe.Fd()
+	probe.Close()
+
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		t.Fatalf("Failed to get the open file limit: %v", err)
+	}
+	lowered := limit
+	lowered.Cur = uint64(nextFD) + 16
+	if err := syscall.Setrlimit(syscall.RLIMIT_NOFILE, &lowered); err != nil {
+		t.Skipf("Cannot lower the open file limit: %v", err)
+	}
+	defer syscall.Setrlimit(syscall.RLIMIT_NOFILE, &limit)
+
+	exp := NewTableExporter(db, "events", []string{"id", "name"}, outputDir)
+	exp.MaxRowsPerFile = 1
+	if err := exp.Export(); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	syscall.Setrlimit(syscall.RLIMIT_NOFILE, &limit)
+
+	paths := exp.OutputPaths()
+	if len(paths) != 200 {
+		t.Fatalf("OutputPaths() has %d parts, want 200", len(paths))
+	}
+	content, err := os.ReadFile(paths[len(paths)-1])
+	if err != nil {
+		t.Fatalf("Failed to read last part: %v", err)
+	}
+	if want := "id,name\n200,event200\n"; string(content) != want {
+		t.Errorf("Last part = %q, want %q", content, want)
+	}
+}
@@ -0,0 +1,202 @@
+package exporter
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"sql2csv/pkg/database"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ParseSQLDialect validates the database type whose quoting rules the sql
+// format follows; the empty string keeps the source type
+func ParseSQLDialect(name string) (database.DBType, error) {
+	switch dialect := database.DBType(name); dialect {
+	case "", database.MySQL, database.MariaDB, database.Postgres, database.SQLite, database.SQLServer:
+		return dialect, nil
+	}
+	return "", fmt.Errorf("unknown SQL dialect %q, want mysql, mariadb, postgres, sqlite3 or sqlserver", name)
+}
+
+// sqlBatchWriter writes rows as multi-row INSERT statements, one statement
+// per batch
+type sqlBatchWriter struct {
+	writer  *bufio.Writer
+	dialect database.DBType
+	table   string
+	prefix  string
+	binary  []bool // columns whose bytes are written as hex literals
+}
+
+func newSQLBatchWriter(w io.Writer, dialect database.DBType, table string) *sqlBatchWriter {
+	return &sqlBatchWriter{
+		writer:  bufio.NewWriter(w),
+		dialect: dialect,
+		table:   table,
+	}
+}
+
+func (s *sqlBatchWriter) WriteHeader(columns []string, types []*sql.ColumnType) error {
+	// There is no header line in a SQL file, but every statement shares the
+	// same column list
+	quoted := make([]string, len(columns))
+	s.binary = make([]bool, len(columns))
+	for i, column := range columns {
+		quoted[i] = database.QuoteIdentifier(s.dialect, column)
+		if i < len(types) && types[i] != nil {
+			s.binary[i] = isBinaryType(types[i].DatabaseTypeName())
+		}
+	}
+	s.prefix = fmt.Sprintf("INSERT INTO %s (%s) VALUES\n",
+		database.QuoteIdentifier(s.dialect, s.table),
+		strings.Join(quoted, ", "))
+	return nil
+}
+
+// sqlServerMaxRows is the most row value expressions SQL Server accepts in
+// one INSERT statement
+const sqlServerMaxRows = 1000
+
+func (s *sqlBatchWriter) WriteBatch(rows [][]interface{}) error {
+	if s.dialect == database.SQLServer {
+		for len(rows) > sqlServerMaxRows {
+			if err := s.writeStatement(rows[:sqlServerMaxRows]); err != nil {
+				return err
+			}
+			rows = rows[sqlServerMaxRows:]
+		}
+	}
+	return s.writeStatement(rows)
+}
+
+// writeStatement writes rows as a single multi-row INSERT statement
+func (s *sqlBatchWriter) writeStatement(rows [][]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	if _, err := s.writer.WriteString(s.prefix); err != nil {
+		return err
+	}
+
+	for i, row := range rows {
+		literals := make([]string, len(row))
+		for j, val := range row {
+			if b, ok := val.([]byte); ok && j < len(s.binary) && s.binary[j] {
+				// Binary values that happen to be valid UTF-8 are still binary
+				literals[j] = binaryLiteral(s.dialect, b)
+				continue
+			}
+			literals[j] = sqlLiteral(s.dialect, val)
+		}
+
+		sep := ",\n"
+		if i == len(rows)-1 {
+			sep = ";\n"
+		}
+		if _, err := fmt.Fprintf(s.writer, "(%s)%s", strings.Join(literals, ", "), sep); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *sqlBatchWriter) Close() error {
+	return s.writer.Flush()
+}
+
+func (s *sqlBatchWriter) flush() error {
+	return s.writer.Flush()
+}
+
+// sqlLiteral renders a scanned value as a SQL literal for the given dialect
+func sqlLiteral(dialect database.DBType, v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		// SQL Server has no boolean literals; bit columns take 1 and 0
+		if dialect == database.SQLServer {
+			if v {
+				return "1"
+			}
+			return "0"
+		}
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", v)
+	case float32:
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return specialFloatLiteral(dialect, float64(v))
+		}
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return specialFloatLiteral(dialect, v)
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		switch dialect {
+		case database.Postgres:
+			return database.QuoteLiteral(dialect, v.Format("2006-01-02 15:04:05.999999999-07:00"))
+		case database.SQLServer:
+			// datetime2 keeps at most seven fractional digits
+			return database.QuoteLiteral(dialect, v.Format("2006-01-02 15:04:05.9999999"))
+		}
+		return database.QuoteLiteral(dialect, v.Format("2006-01-02 15:04:05.999999999"))
+	case []byte:
+		// Drivers return text columns as bytes too, so unless the column
+		// type is known to be binary, only treat values that aren't
+		// printable text as binary
+		if utf8.Valid(v) && !strings.ContainsRune(string(v), 0) {
+			return database.QuoteLiteral(dialect, string(v))
+		}
+		return binaryLiteral(dialect, v)
+	case string:
+		return database.QuoteLiteral(dialect, v)
+	default:
+		return database.QuoteLiteral(dialect, fmt.Sprintf("%v", v))
+	}
+}
+
+// specialFloatLiteral renders NaN or an infinity, which have no numeric
+// literal. Postgres takes them as quoted floats and SQLite reads an
+// overflowing literal as infinity; the other dialects have no such values,
+// so they are written as NULL, like the JSON format does.
+func specialFloatLiteral(dialect database.DBType, f float64) string {
+	switch {
+	case dialect == database.Postgres && math.IsNaN(f):
+		return "'NaN'::float8"
+	case dialect == database.Postgres:
+		if f > 0 {
+			return "'Infinity'::float8"
+		}
+		return "'-Infinity'::float8"
+	case dialect == database.SQLite && math.IsInf(f, 1):
+		return "9e999"
+	case dialect == database.SQLite && math.IsInf(f, -1):
+		return "-9e999"
+	}
+	return "NULL"
+}
+
+// binaryLiteral renders binary data as a hex literal for the given dialect
+func binaryLiteral(dialect database.DBType, b []byte) string {
+	switch dialect {
+	case database.Postgres:
+		return `'\x` + hex.EncodeToString(b) + `'::bytea`
+	case database.SQLServer:
+		return "0x" + hex.EncodeToString(b)
+	}
+	return "X'" + hex.EncodeToString(b) + "'"
+}
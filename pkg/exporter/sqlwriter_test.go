@@ -0,0 +1,181 @@
+package exporter
+
+import (
+	"bytes"
+	"database/sql"
+	"math"
+	"os"
+	"path/filepath"
+	"sql2csv/pkg/database"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestSQLLiteral(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect database.DBType
+		input   interface{}
+		want    string
+	}{
+		{name: "Nil value", dialect: database.SQLite, input: nil, want: "NULL"},
+		{name: "Integer value", dialect: database.SQLite, input: int64(42), want: "42"},
+		{name: "Float value", dialect: database.SQLite, input: 1.5, want: "1.5"},
+		{name: "Small integer value", dialect: database.SQLite, input: int32(7), want: "7"},
+		{name: "Float32 value", dialect: database.SQLite, input: float32(0.25), want: "0.25"},
+		{name: "Bool value", dialect: database.Postgres, input: true, want: "TRUE"},
+		{name: "String with quote", dialect: database.Postgres, input: "O'Brien", want: "'O''Brien'"},
+		{name: "MySQL backslash", dialect: database.MySQL, input: `C:\temp`, want: `'C:\\temp'`},
+		{name: "Text bytes", dialect: database.MySQL, input: []byte("hello"), want: "'hello'"},
+		{name: "Binary bytes", dialect: database.SQLite, input: []byte{0x00, 0xff}, want: "X'00ff'"},
+		{name: "Postgres binary bytes", dialect: database.Postgres, input: []byte{0x00, 0xff}, want: `'\x00ff'::bytea`},
+		{name: "Postgres NaN", dialect: database.Postgres, input: math.NaN(), want: "'NaN'::float8"},
+		{name: "Postgres negative infinity", dialect: database.Postgres, input: math.Inf(-1), want: "'-Infinity'::float8"},
+		{name: "SQLite infinity", dialect: database.SQLite, input: float32(math.Inf(1)), want: "9e999"},
+		{name: "MySQL NaN", dialect: database.MySQL, input: math.NaN(), want: "NULL"},
+		{name: "SQL Server bool", dialect: database.SQLServer, input: false, want: "0"},
+		{name: "SQL Server binary bytes", dialect: database.SQLServer, input: []byte{0x00, 0xff}, want: "0x00ff"},
+		{name: "SQL Server time", dialect: database.SQLServer, input: time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC), want: "N'2024-01-02 03:04:05.1234567'"},
+		{name: "SQL Server text bytes", dialect: database.SQLServer, input: []byte("naïve"), want: "N'naïve'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sqlLiteral(tt.dialect, tt.input); got != tt.want {
+				t.Errorf("sqlLiteral() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSQLDialect(t *testing.T) {
+	for _, name := range []string{"", "mysql", "postgres", "sqlserver"} {
+		if got, err := ParseSQLDialect(name); err != nil || got != database.DBType(name) {
+			t.Errorf("ParseSQLDialect(%q) = %q, %v", name, got, err)
+		}
+	}
+	if _, err := ParseSQLDialect("oracle"); err == nil {
+		t.Error("ParseSQLDialect(oracle) returned no error")
+	}
+}
+
+func TestSQLBatchWriter_SQLServerRowLimit(t *testing.T) {
+	rows := make([][]interface{}, 2500)
+	for i := range rows {
+		rows[i] = []interface{}{int64(i)}
+	}
+
+	tests := []struct {
+		name    string
+		dialect database.DBType
+		want    int
+	}{
+		{name: "SQL Server splits every 1000 rows", dialect: database.SQLServer, want: 3},
+		{name: "Postgres writes one statement", dialect: database.Postgres, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := newSQLBatchWriter(&buf, tt.dialect, "events")
+			if err := w.WriteHeader([]string{"id"}, nil); err != nil {
+				t.Fatalf("WriteHeader() error = %v", err)
+			}
+			if err := w.WriteBatch(rows); err != nil {
+				t.Fatalf("WriteBatch() error = %v", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			out := buf.String()
+			if got := strings.Count(out, "INSERT INTO"); got != tt.want {
+				t.Errorf("got %d INSERT statements, want %d", got, tt.want)
+			}
+			if got := strings.Count(out, ";\n"); got != tt.want {
+				t.Errorf("got %d terminated statements, want %d", got, tt.want)
+			}
+			if got := strings.Count(out, "\n("); got != len(rows) {
+				t.Errorf("got %d rows, want %d", got, len(rows))
+			}
+		})
+	}
+}
+
+func TestTableExporter_ExportSQL(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE test_table (
+			id INTEGER PRIMARY KEY,
+			name TEXT,
+			data BLOB
+		);
+		INSERT INTO test_table (name, data) VALUES
+		('O''Brien', X'00ff'),
+		('text-like blob', X'5c7834315c'),
+		(NULL, NULL);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	outputDir, err := os.MkdirTemp("", "sql_output")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	exp := NewTableExporter(db, "test_table", []string{"id", "name", "data"}, outputDir)
+	exp.Format = SQL
+	exp.Dialect = database.SQLite
+	if err := exp.Export(); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	if exp.OutputPath() != filepath.Join(outputDir, "test_table.sql") {
+		t.Errorf("OutputPath() = %s, want test_table.sql", exp.OutputPath())
+	}
+
+	content, err := os.ReadFile(exp.OutputPath())
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+
+	// Replay the statements into a copy of the table and compare
+	_, err = db.Exec(`CREATE TABLE copy_table (id INTEGER PRIMARY KEY, name TEXT, data BLOB)`)
+	if err != nil {
+		t.Fatalf("Failed to create copy table: %v", err)
+	}
+	copySQL := `INSERT INTO "copy_table"` + string(content)[len(`INSERT INTO "test_table"`):]
+	if _, err := db.Exec(copySQL); err != nil {
+		t.Fatalf("Failed to replay exported SQL: %v\n%s", err, content)
+	}
+
+	var mismatches int
+	err = db.QueryRow(`
+		SELECT COUNT(*) FROM test_table t
+		LEFT JOIN copy_table c ON c.id = t.id
+		WHERE c.id IS NULL OR c.name IS NOT t.name OR c.data IS NOT t.data
+	`).Scan(&mismatches)
+	if err != nil {
+		t.Fatalf("Failed to compare tables: %v", err)
+	}
+	if mismatches != 0 {
+		t.Errorf("%d rows differ after replaying exported SQL:\n%s", mismatches, content)
+	}
+}
@@ -0,0 +1,316 @@
+package exporter
+
+import (
+	"archive/zip"
+	"bufio"
+	"database/sql"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Limits of an Excel worksheet
+const (
+	xlsxMaxRows       = 1048576
+	xlsxMaxColumns    = 16384
+	xlsxMaxCellLength = 32767
+)
+
+// Cell styles defined in xlsxStyles
+const (
+	xlsxStyleDateTime = 1
+	xlsxStyleHeader   = 2
+)
+
+// xlsxEpoch is day 0 of Excel's date serial numbers. Serials before
+// 1900-03-01 are off by Excel's fictitious 1900-02-29, so earlier times are
+// written as text.
+var (
+	xlsxEpoch     = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	xlsxMinSerial = time.Date(1900, 3, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// xlsxBatchWriter writes rows as an Excel workbook with a single worksheet
+// named after the table. Rows are streamed into the worksheet entry of the
+// zip archive, so the table is never held in memory.
+type xlsxBatchWriter struct {
+	zip       *zip.Writer
+	sheet     *bufio.Writer
+	sheetName string
+	refs      []string // column letters
+	numeric   []bool   // columns whose text values are numbers
+	binary    []bool   // columns whose bytes are base64-encoded
+	rows      int      // rows written, including the header
+}
+
+func newXLSXBatchWriter(w io.Writer, sheetName string) *xlsxBatchWriter {
+	return &xlsxBatchWriter{zip: zip.NewWriter(w), sheetName: xlsxSheetName(sheetName)}
+}
+
+func (x *xlsxBatchWriter) WriteHeader(columns []string, types []*sql.ColumnType) error {
+	if len(columns) > xlsxMaxColumns {
+		return fmt.Errorf("%d columns don't fit in an Excel worksheet, which holds %d", len(columns), xlsxMaxColumns)
+	}
+	x.refs = make([]string, len(columns))
+	x.numeric = make([]bool, len(columns))
+	x.binary = make([]bool, len(columns))
+	for i := range columns {
+		x.refs[i] = xlsxColumn(i)
+		if i < len(types) && types[i] != nil {
+			x.numeric[i] = isNumericType(types[i].DatabaseTypeName())
+			x.binary[i] = isBinaryType(types[i].DatabaseTypeName())
+		}
+	}
+
+	// The worksheet is written last, as a zip entry can't be reopened once
+	// the next one is created
+	var workbook strings.Builder
+	workbook.WriteString(xml.Header)
+	workbook.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="`)
+	xml.EscapeText(&workbook, []byte(x.sheetName))
+	workbook.WriteString(`" sheetId="1" r:id="rId1"/></sheets></workbook>`)
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", workbook.String()},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for _, part := range parts {
+		f, err := x.zip.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return err
+		}
+	}
+
+	f, err := x.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	x.sheet = bufio.NewWriter(f)
+	// Keep the header row in view while scrolling
+	x.sheet.WriteString(xml.Header)
+	x.sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>` +
+		`<sheetData>`)
+
+	header := make([]interface{}, len(columns))
+	for i, column := range columns {
+		header[i] = column
+	}
+	return x.writeRow(header, xlsxStyleHeader)
+}
+
+func (x *xlsxBatchWriter) WriteBatch(rows [][]interface{}) error {
+	for _, row := range rows {
+		if err := x.writeRow(row, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *xlsxBatchWriter) Close() error {
+	if x.sheet != nil {
+		if _, err := x.sheet.WriteString(`</sheetData></worksheet>`); err != nil {
+			return err
+		}
+		if err := x.sheet.Flush(); err != nil {
+			return err
+		}
+	}
+	return x.zip.Close()
+}
+
+// writeRow writes one worksheet row. NULL cells are left out, so every cell
+// carries its reference.
+func (x *xlsxBatchWriter) writeRow(row []interface{}, style int) error {
+	if x.rows == xlsxMaxRows {
+		return fmt.Errorf("the export has more rows than an Excel worksheet holds (%d including the header)", xlsxMaxRows)
+	}
+	x.rows++
+	r := strconv.Itoa(x.rows)
+
+	x.sheet.WriteString(`<row r="` + r + `">`)
+	for i, val := range row {
+		value, typ, cellStyle, err := x.cellValue(i, val)
+		if err != nil {
+			return err
+		}
+		if typ == "" {
+			continue // NULL
+		}
+		if style != 0 {
+			cellStyle = style
+		}
+
+		x.sheet.WriteString(`<c r="` + x.refs[i] + r + `"`)
+		if cellStyle != 0 {
+			x.sheet.WriteString(` s="` + strconv.Itoa(cellStyle) + `"`)
+		}
+		switch typ {
+		case "inlineStr":
+			x.sheet.WriteString(` t="inlineStr"><is><t xml:space="preserve">`)
+			xml.EscapeText(x.sheet, []byte(value))
+			x.sheet.WriteString(`</t></is></c>`)
+		case "b":
+			x.sheet.WriteString(` t="b"><v>` + value + `</v></c>`)
+		default:
+			x.sheet.WriteString(`><v>` + value + `</v></c>`)
+		}
+	}
+	_, err := x.sheet.WriteString(`</row>`)
+	return err
+}
+
+// cellValue returns the text of a cell, its type ("n" for numbers, "b" for
+// booleans, "inlineStr" for text, "" for NULL) and style. Numbers and times
+// become native Excel values where they survive the conversion.
+func (x *xlsxBatchWriter) cellValue(i int, v interface{}) (value, typ string, style int, err error) {
+	switch val := v.(type) {
+	case nil:
+		return "", "", 0, nil
+	case bool:
+		if val {
+			return "1", "b", 0, nil
+		}
+		return "0", "b", 0, nil
+	case int64:
+		// Excel numbers are doubles, exact up to 2^53
+		if val > -1<<53 && val < 1<<53 {
+			return strconv.FormatInt(val, 10), "n", 0, nil
+		}
+		return x.text(i, strconv.FormatInt(val, 10))
+	case int, int8, int16, int32, uint8, uint16, uint32:
+		return fmt.Sprintf("%d", val), "n", 0, nil
+	case float64:
+		if !math.IsNaN(val) && !math.IsInf(val, 0) {
+			return strconv.FormatFloat(val, 'g', -1, 64), "n", 0, nil
+		}
+	case float32:
+		if !math.IsNaN(float64(val)) && !math.IsInf(float64(val), 0) {
+			return strconv.FormatFloat(float64(val), 'g', -1, 32), "n", 0, nil
+		}
+	case time.Time:
+		if serial, ok := xlsxSerial(val); ok {
+			return serial, "n", xlsxStyleDateTime, nil
+		}
+		return x.text(i, val.Format(time.RFC3339Nano))
+	case []byte:
+		if x.numeric[i] && xlsxExactNumber(string(val)) {
+			return string(val), "n", 0, nil
+		}
+		if x.binary[i] || !utf8.Valid(val) {
+			return x.text(i, base64.StdEncoding.EncodeToString(val))
+		}
+		return x.text(i, string(val))
+	case string:
+		return x.text(i, val)
+	}
+	return x.text(i, fmt.Sprintf("%v", v))
+}
+
+// text returns a text cell, failing for text longer than a cell holds
+func (x *xlsxBatchWriter) text(i int, s string) (string, string, int, error) {
+	if utf8.RuneCountInString(s) > xlsxMaxCellLength {
+		return "", "", 0, fmt.Errorf("a value in column %s has more than the %d characters an Excel cell holds", x.refs[i], xlsxMaxCellLength)
+	}
+	return s, "inlineStr", 0, nil
+}
+
+// xlsxExactNumber reports whether a number's text can be stored as an
+// Excel number without losing digits
+func xlsxExactNumber(s string) bool {
+	if !isJSONNumber(s) {
+		return false
+	}
+	digits := 0
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			digits++
+		}
+		if c == 'e' || c == 'E' {
+			break
+		}
+	}
+	return digits <= 15
+}
+
+// xlsxSerial converts a time's wall clock to an Excel date serial number
+func xlsxSerial(t time.Time) (string, bool) {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	if wall.Before(xlsxMinSerial) || wall.Year() > 9999 {
+		return "", false
+	}
+	days := float64(wall.Sub(xlsxEpoch)) / float64(24*time.Hour)
+	return strconv.FormatFloat(days, 'f', -1, 64), true
+}
+
+// xlsxColumn returns the letters of the zero-based column i, e.g. A or AB
+func xlsxColumn(i int) string {
+	var letters []byte
+	for i++; i > 0; i = (i - 1) / 26 {
+		letters = append([]byte{byte('A' + (i-1)%26)}, letters...)
+	}
+	return string(letters)
+}
+
+// xlsxSheetName makes a table name a valid worksheet name: at most 31
+// characters, none of them []:*?/\
+func xlsxSheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if runes := []rune(name); len(runes) > 31 {
+		name = string(runes[:31])
+	}
+	if name == "" {
+		return "Sheet1"
+	}
+	return name
+}
+
+const xlsxContentTypes = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+	`</Types>`
+
+const xlsxRootRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbookRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`</Relationships>`
+
+// xlsxStyles defines the default style, a date and time format and a bold
+// header
+const xlsxStyles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts>` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="3">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`</cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`
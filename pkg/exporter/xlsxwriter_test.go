@@ -0,0 +1,156 @@
+package exporter
+
+import (
+	"archive/zip"
+	"database/sql"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestTableExporter_XLSX(t *testing.T) {
+	// Create a temporary SQLite database for testing
+	tmpfile, err := os.CreateTemp("", "test.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, score REAL, active BOOLEAN, note TEXT);
+		INSERT INTO users (name, score, active, note) VALUES ('Alice & Bob', 2.5, 1, NULL);
+		INSERT INTO users (name, score, active, note) VALUES ('<b>', NULL, 0, ' padded ');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	outputDir := t.TempDir()
+	exp := NewTableExporter(db, "users", []string{"id", "name", "score", "active", "note"}, outputDir)
+	exp.Format = XLSX
+	if err := exp.Export(); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if !strings.HasSuffix(exp.OutputPath(), "users.xlsx") {
+		t.Errorf("OutputPath() = %s, want users.xlsx", exp.OutputPath())
+	}
+
+	z, err := zip.OpenReader(exp.OutputPath())
+	if err != nil {
+		t.Fatalf("Failed to open workbook: %v", err)
+	}
+	defer z.Close()
+	parts := make(map[string]string)
+	for _, f := range z.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", f.Name, err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", f.Name, err)
+		}
+		parts[f.Name] = string(data)
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("workbook has no %s", name)
+		}
+	}
+	if !strings.Contains(parts["xl/workbook.xml"], `<sheet name="users"`) {
+		t.Errorf("workbook.xml = %s, want a sheet named users", parts["xl/workbook.xml"])
+	}
+
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`<c r="A1" s="2" t="inlineStr"><is><t xml:space="preserve">id</t></is></c>`,
+		`<c r="A2"><v>1</v></c>`,
+		`<c r="B2" t="inlineStr"><is><t xml:space="preserve">Alice &amp; Bob</t></is></c>`,
+		`<c r="C2"><v>2.5</v></c>`,
+		`<c r="D2" t="b"><v>1</v></c>`,
+		`<c r="B3" t="inlineStr"><is><t xml:space="preserve">&lt;b&gt;</t></is></c>`,
+		`<c r="E3" t="inlineStr"><is><t xml:space="preserve"> padded </t></is></c>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet1.xml lacks %s:\n%s", want, sheet)
+		}
+	}
+	// NULL cells are left out
+	for _, unwanted := range []string{`r="E2"`, `r="C3"`} {
+		if strings.Contains(sheet, unwanted) {
+			t.Errorf("sheet1.xml has NULL cell %s:\n%s", unwanted, sheet)
+		}
+	}
+
+	exp.Compress = true
+	if err := exp.Export(); err == nil {
+		t.Error("Export() with Compress succeeded, want an error")
+	}
+}
+
+func TestXLSXCellValue(t *testing.T) {
+	x := &xlsxBatchWriter{refs: []string{"A"}, numeric: []bool{true}, binary: []bool{false}}
+	tests := []struct {
+		name      string
+		value     interface{}
+		wantValue string
+		wantType  string
+		wantStyle int
+		wantErr   bool
+	}{
+		{name: "NULL", value: nil},
+		{name: "Integer", value: int64(42), wantValue: "42", wantType: "n"},
+		{name: "Integer beyond a double", value: int64(1 << 60), wantValue: "1152921504606846976", wantType: "inlineStr"},
+		{name: "Float", value: 0.1, wantValue: "0.1", wantType: "n"},
+		{name: "Boolean", value: false, wantValue: "0", wantType: "b"},
+		{name: "Numeric text", value: []byte("123.45"), wantValue: "123.45", wantType: "n"},
+		{name: "Long numeric text", value: []byte("1234567890.1234567"), wantValue: "1234567890.1234567", wantType: "inlineStr"},
+		{name: "Date", value: time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC), wantValue: "45293.5", wantType: "n", wantStyle: xlsxStyleDateTime},
+		{name: "Date before 1900-03-01", value: time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC), wantValue: "1900-01-01T00:00:00Z", wantType: "inlineStr"},
+		{name: "Text too long", value: strings.Repeat("x", xlsxMaxCellLength+1), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, typ, style, err := x.cellValue(0, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("cellValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if value != tt.wantValue || typ != tt.wantType || style != tt.wantStyle {
+				t.Errorf("cellValue() = %q, %q, %d, want %q, %q, %d", value, typ, style, tt.wantValue, tt.wantType, tt.wantStyle)
+			}
+		})
+	}
+}
+
+func TestXLSXNames(t *testing.T) {
+	columns := map[int]string{0: "A", 25: "Z", 26: "AA", 701: "ZZ", 702: "AAA", xlsxMaxColumns - 1: "XFD"}
+	for i, want := range columns {
+		if got := xlsxColumn(i); got != want {
+			t.Errorf("xlsxColumn(%d) = %s, want %s", i, got, want)
+		}
+	}
+
+	sheets := map[string]string{
+		"users":      "users",
+		"sales/2024": "sales_2024",
+		"a_table_name_that_is_too_long_for_excel": "a_table_name_that_is_too_long_f",
+	}
+	for name, want := range sheets {
+		if got := xlsxSheetName(name); got != want {
+			t.Errorf("xlsxSheetName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}